@@ -1,26 +1,92 @@
 package main
 
 import (
+	"context"
 	"cse224/proj4/pkg/surfstore"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"os/signal"
 	"strconv"
+	"syscall"
+	"text/tabwriter"
+	"time"
 )
 
 // Arguments
 const ARG_COUNT int = 3
 
 // Usage strings
-const USAGE_STRING = "./run-client.sh -d host:port baseDir blockSize"
+const USAGE_STRING = "./run-client.sh -d [-history file | -restore file -version n | -plan [-json]] [-w workers] [-xattrs] [-safe-links] [-conflict policy] [-merge off|clean|markers] [-chunker fixed|fastcdc] [-cdc-min n] [-cdc-avg n] [-cdc-max n] [-meta-timeout t] [-list-timeout t] [-block-timeout t] [-retries n] [-backoff t] [-max-backoff t] host:port baseDir blockSize"
 
 const DEBUG_NAME = "d"
 const DEBUG_USAGE = "Output log statements"
 
+const HISTORY_NAME = "history"
+const HISTORY_USAGE = "List the versions of a file on the server instead of syncing"
+
+const RESTORE_NAME = "restore"
+const RESTORE_USAGE = "Make the version of a file given by -version current again, then sync"
+
+const VERSION_NAME = "version"
+const VERSION_USAGE = "Version restored by -restore"
+
+const PLAN_NAME = "plan"
+const PLAN_USAGE = "Print what a sync would upload, download, delete and settle as conflicts, without changing anything"
+
+const JSON_NAME = "json"
+const JSON_USAGE = "Print the plan as JSON"
+
+const WORKERS_NAME = "w"
+const WORKERS_USAGE = "Number of blocks transferred concurrently"
+
+const XATTRS_NAME = "xattrs"
+const XATTRS_USAGE = "Sync the user extended attributes of files (Linux only)"
+
+const SAFE_LINKS_NAME = "safe-links"
+const SAFE_LINKS_USAGE = "Refuse to sync symlinks that point outside baseDir"
+
+const CONFLICT_NAME = "conflict"
+const CONFLICT_USAGE = "How a file changed locally and on the server is settled: server-wins, client-wins, newest-wins or keep-both"
+
+const MERGE_NAME = "merge"
+const MERGE_USAGE = "When text files changed locally and on the server are merged: off, clean when the changes do not overlap, or markers to merge overlapping changes between conflict markers"
+
+const CHUNKER_NAME = "chunker"
+const CHUNKER_USAGE = "How files are split into blocks: fixed blockSize blocks, or content-defined (fastcdc) blocks"
+
+const CDC_MIN_NAME = "cdc-min"
+const CDC_MIN_USAGE = "Smallest fastcdc block, a quarter of the average by default"
+
+const CDC_AVG_NAME = "cdc-avg"
+const CDC_AVG_USAGE = "Average fastcdc block, blockSize by default"
+
+const CDC_MAX_NAME = "cdc-max"
+const CDC_MAX_USAGE = "Largest fastcdc block, four times the average by default"
+
+const META_TIMEOUT_NAME = "meta-timeout"
+const META_TIMEOUT_USAGE = "Deadline of UpdateFile, RestoreFileVersion, GetBlockStoreMap and GetBlockStoreAddrs calls"
+
+const LIST_TIMEOUT_NAME = "list-timeout"
+const LIST_TIMEOUT_USAGE = "Deadline of GetFileInfoMap and GetFileHistory calls"
+
+const BLOCK_TIMEOUT_NAME = "block-timeout"
+const BLOCK_TIMEOUT_USAGE = "Deadline of BlockStore calls"
+
+const RETRIES_NAME = "retries"
+const RETRIES_USAGE = "Times an idempotent call is retried after a transient failure"
+
+const BACKOFF_NAME = "backoff"
+const BACKOFF_USAGE = "Backoff before the first retry, doubled after each retry"
+
+const MAX_BACKOFF_NAME = "max-backoff"
+const MAX_BACKOFF_USAGE = "Upper bound of the backoff between retries"
+
 const ADDR_NAME = "host:port"
-const ADDR_USAGE = "IP address and port of the MetaStore the client is syncing to"
+const ADDR_USAGE = "IP address and port of the MetaStore the client is syncing to, or a comma-separated list of all raft MetaStore replicas"
 
 const BASEDIR_NAME = "baseDir"
 const BASEDIR_USAGE = "Base directory of the client"
@@ -37,6 +103,26 @@ func main() {
 		w := flag.CommandLine.Output()
 		fmt.Fprintf(w, "Usage of %s:\n", USAGE_STRING)
 		fmt.Fprintf(w, "  -%s: %v\n", DEBUG_NAME, DEBUG_USAGE)
+		fmt.Fprintf(w, "  -%s: %v\n", HISTORY_NAME, HISTORY_USAGE)
+		fmt.Fprintf(w, "  -%s: %v\n", RESTORE_NAME, RESTORE_USAGE)
+		fmt.Fprintf(w, "  -%s: %v\n", VERSION_NAME, VERSION_USAGE)
+		fmt.Fprintf(w, "  -%s: %v\n", PLAN_NAME, PLAN_USAGE)
+		fmt.Fprintf(w, "  -%s: %v\n", JSON_NAME, JSON_USAGE)
+		fmt.Fprintf(w, "  -%s: %v (default %v)\n", WORKERS_NAME, WORKERS_USAGE, surfstore.DEFAULT_TRANSFER_WORKERS)
+		fmt.Fprintf(w, "  -%s: %v\n", XATTRS_NAME, XATTRS_USAGE)
+		fmt.Fprintf(w, "  -%s: %v\n", SAFE_LINKS_NAME, SAFE_LINKS_USAGE)
+		fmt.Fprintf(w, "  -%s: %v (default %v)\n", CONFLICT_NAME, CONFLICT_USAGE, surfstore.CONFLICT_KEEP_BOTH)
+		fmt.Fprintf(w, "  -%s: %v (default %v)\n", MERGE_NAME, MERGE_USAGE, surfstore.MERGE_CLEAN)
+		fmt.Fprintf(w, "  -%s: %v (default %v)\n", CHUNKER_NAME, CHUNKER_USAGE, surfstore.CHUNKING_FIXED)
+		fmt.Fprintf(w, "  -%s: %v\n", CDC_MIN_NAME, CDC_MIN_USAGE)
+		fmt.Fprintf(w, "  -%s: %v\n", CDC_AVG_NAME, CDC_AVG_USAGE)
+		fmt.Fprintf(w, "  -%s: %v\n", CDC_MAX_NAME, CDC_MAX_USAGE)
+		fmt.Fprintf(w, "  -%s: %v (default %v)\n", META_TIMEOUT_NAME, META_TIMEOUT_USAGE, surfstore.DEFAULT_META_TIMEOUT)
+		fmt.Fprintf(w, "  -%s: %v (default %v)\n", LIST_TIMEOUT_NAME, LIST_TIMEOUT_USAGE, surfstore.DEFAULT_LIST_TIMEOUT)
+		fmt.Fprintf(w, "  -%s: %v (default %v)\n", BLOCK_TIMEOUT_NAME, BLOCK_TIMEOUT_USAGE, surfstore.DEFAULT_BLOCK_TIMEOUT)
+		fmt.Fprintf(w, "  -%s: %v (default %v)\n", RETRIES_NAME, RETRIES_USAGE, surfstore.DEFAULT_MAX_RETRIES)
+		fmt.Fprintf(w, "  -%s: %v (default %v)\n", BACKOFF_NAME, BACKOFF_USAGE, surfstore.DEFAULT_BASE_BACKOFF)
+		fmt.Fprintf(w, "  -%s: %v (default %v)\n", MAX_BACKOFF_NAME, MAX_BACKOFF_USAGE, surfstore.DEFAULT_MAX_BACKOFF)
 		fmt.Fprintf(w, "  %s: %v\n", ADDR_NAME, ADDR_USAGE)
 		fmt.Fprintf(w, "  %s: %v\n", BASEDIR_NAME, BASEDIR_USAGE)
 		fmt.Fprintf(w, "  %s: %v\n", BLOCK_NAME, BLOCK_USAGE)
@@ -44,6 +130,26 @@ func main() {
 
 	// Parse command-line arguments and flags
 	debug := flag.Bool("d", false, DEBUG_USAGE)
+	history := flag.String(HISTORY_NAME, "", HISTORY_USAGE)
+	restore := flag.String(RESTORE_NAME, "", RESTORE_USAGE)
+	version := flag.Int(VERSION_NAME, 0, VERSION_USAGE)
+	plan := flag.Bool(PLAN_NAME, false, PLAN_USAGE)
+	jsonOutput := flag.Bool(JSON_NAME, false, JSON_USAGE)
+	workers := flag.Int(WORKERS_NAME, surfstore.DEFAULT_TRANSFER_WORKERS, WORKERS_USAGE)
+	xattrs := flag.Bool(XATTRS_NAME, false, XATTRS_USAGE)
+	safeLinks := flag.Bool(SAFE_LINKS_NAME, false, SAFE_LINKS_USAGE)
+	conflictPolicy := flag.String(CONFLICT_NAME, surfstore.CONFLICT_KEEP_BOTH, CONFLICT_USAGE)
+	merge := flag.String(MERGE_NAME, surfstore.MERGE_CLEAN, MERGE_USAGE)
+	chunkerName := flag.String(CHUNKER_NAME, surfstore.CHUNKING_FIXED, CHUNKER_USAGE)
+	cdcMin := flag.Int(CDC_MIN_NAME, 0, CDC_MIN_USAGE)
+	cdcAvg := flag.Int(CDC_AVG_NAME, 0, CDC_AVG_USAGE)
+	cdcMax := flag.Int(CDC_MAX_NAME, 0, CDC_MAX_USAGE)
+	metaTimeout := flag.Duration(META_TIMEOUT_NAME, surfstore.DEFAULT_META_TIMEOUT, META_TIMEOUT_USAGE)
+	listTimeout := flag.Duration(LIST_TIMEOUT_NAME, surfstore.DEFAULT_LIST_TIMEOUT, LIST_TIMEOUT_USAGE)
+	blockTimeout := flag.Duration(BLOCK_TIMEOUT_NAME, surfstore.DEFAULT_BLOCK_TIMEOUT, BLOCK_TIMEOUT_USAGE)
+	retries := flag.Int(RETRIES_NAME, surfstore.DEFAULT_MAX_RETRIES, RETRIES_USAGE)
+	backoff := flag.Duration(BACKOFF_NAME, surfstore.DEFAULT_BASE_BACKOFF, BACKOFF_USAGE)
+	maxBackoff := flag.Duration(MAX_BACKOFF_NAME, surfstore.DEFAULT_MAX_BACKOFF, MAX_BACKOFF_USAGE)
 	flag.Parse()
 
 	// Use tail arguments to hold non-flag arguments
@@ -61,6 +167,44 @@ func main() {
 		flag.Usage()
 		os.Exit(EX_USAGE)
 	}
+	if (*history != "" && *restore != "") || (*restore != "") != (*version > 0) ||
+		(*plan && (*history != "" || *restore != "")) || (*jsonOutput && !*plan) {
+		flag.Usage()
+		os.Exit(EX_USAGE)
+	}
+	if *merge != surfstore.MERGE_OFF && *merge != surfstore.MERGE_CLEAN && *merge != surfstore.MERGE_MARKERS {
+		flag.Usage()
+		os.Exit(EX_USAGE)
+	}
+	if *workers <= 0 || *metaTimeout <= 0 || *listTimeout <= 0 || *blockTimeout <= 0 || *retries < 0 || *backoff < 0 || *maxBackoff < *backoff {
+		flag.Usage()
+		os.Exit(EX_USAGE)
+	}
+
+	var chunker surfstore.Chunker
+	switch *chunkerName {
+	case surfstore.CHUNKING_FIXED:
+		chunker, err = surfstore.NewFixedChunker(blockSize)
+	case surfstore.CHUNKING_FASTCDC:
+		if *cdcAvg == 0 {
+			*cdcAvg = blockSize
+		}
+		chunker, err = surfstore.NewFastCDCChunker(*cdcMin, *cdcAvg, *cdcMax)
+	default:
+		err = fmt.Errorf("unknown chunker %q", *chunkerName)
+	}
+	if err != nil {
+		fmt.Fprintln(flag.CommandLine.Output(), err)
+		flag.Usage()
+		os.Exit(EX_USAGE)
+	}
+
+	resolver, err := surfstore.NewConflictResolver(*conflictPolicy)
+	if err != nil {
+		fmt.Fprintln(flag.CommandLine.Output(), err)
+		flag.Usage()
+		os.Exit(EX_USAGE)
+	}
 
 	// Disable log outputs if debug flag is missing
 	if !(*debug) {
@@ -69,5 +213,124 @@ func main() {
 	}
 
 	rpcClient := surfstore.NewSurfstoreRPCClient(hostPort, baseDir, blockSize)
-	surfstore.ClientSync(rpcClient)
+	defer rpcClient.Close()
+	rpcClient.Options = surfstore.RPCOptions{
+		MetaTimeout:  *metaTimeout,
+		ListTimeout:  *listTimeout,
+		BlockTimeout: *blockTimeout,
+		MaxRetries:   *retries,
+		BaseBackoff:  *backoff,
+		MaxBackoff:   *maxBackoff,
+	}
+
+	// an interrupt ends the running call, a sync stops between transfers
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	client := rpcClient.WithContext(ctx)
+
+	if *history != "" {
+		var versions []*surfstore.FileMetaData
+		if err := client.GetFileHistory(*history, &versions); err != nil {
+			fmt.Fprintf(os.Stderr, "Error getting the history of %s: %v\n", *history, err)
+			os.Exit(1)
+		}
+		printHistory(os.Stdout, versions)
+		return
+	}
+	if *restore != "" {
+		var latestVersion int32
+		err := client.RestoreFileVersion(*restore, int32(*version), &latestVersion)
+		if err == nil && latestVersion == -1 {
+			err = fmt.Errorf("%s changed meanwhile, try again", *restore)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error restoring version %d of %s: %v\n", *version, *restore, err)
+			os.Exit(1)
+		}
+		fmt.Printf("Restored version %d of %s as version %d\n", *version, *restore, latestVersion)
+	}
+
+	opts := surfstore.DefaultSyncOptions(baseDir, blockSize)
+	opts.Chunker = chunker
+	opts.Workers = *workers
+	opts.Xattrs = *xattrs
+	opts.SafeLinks = *safeLinks
+	opts.ConflictResolver = resolver
+	opts.Merge = *merge
+	if *plan {
+		syncPlan, err := surfstore.Plan(ctx, client, opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error planning the sync: %v\n", err)
+			os.Exit(1)
+		}
+		if *jsonOutput {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			if err := encoder.Encode(syncPlan); err != nil {
+				fmt.Fprintf(os.Stderr, "Error printing the plan: %v\n", err)
+				os.Exit(1)
+			}
+		} else {
+			printPlan(os.Stdout, syncPlan)
+		}
+		return
+	}
+	report, err := surfstore.Sync(ctx, client, opts)
+	for _, fileAction := range report.Files {
+		if fileAction.Action == surfstore.ActionSkip {
+			fmt.Fprintf(os.Stderr, "Skipping file: %v\n", fileAction.Err)
+		} else {
+			log.Println(fileAction)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error syncing: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println(report.Stats)
+}
+
+// printHistory lists the versions of a file, oldest first
+func printHistory(w io.Writer, versions []*surfstore.FileMetaData) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tCOMMITTED\tSIZE\tCONTENT")
+	for _, fileMetaData := range versions {
+		committed := "-"
+		if fileMetaData.GetCommitTime() != 0 {
+			committed = time.Unix(0, fileMetaData.GetCommitTime()).Format(time.DateTime)
+		}
+		var content string
+		switch {
+		case surfstore.IsTombstone(fileMetaData):
+			content = "deleted"
+		case fileMetaData.GetDirectory():
+			content = "directory"
+		case fileMetaData.GetSymlinkTarget() != "":
+			content = "symlink to " + fileMetaData.GetSymlinkTarget()
+		default:
+			content = fmt.Sprintf("%d blocks", len(surfstore.FileBlockHashes(fileMetaData)))
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%d\t%s\n", fileMetaData.GetVersion(), committed, fileMetaData.GetSize(), content)
+	}
+	tw.Flush()
+}
+
+// printPlan lists what a sync would do with each file, then the totals
+func printPlan(w io.Writer, plan *surfstore.SyncPlan) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "ACTION\tFILE\tUPLOAD\tDOWNLOAD\tNOTE")
+	for _, entry := range plan.Files {
+		note := entry.Reason
+		if entry.Action == surfstore.ActionConflict {
+			note = entry.Resolution
+			if entry.Merge {
+				note = "merge, else " + entry.Resolution
+			}
+		}
+		fmt.Fprintf(tw, "%v\t%s\t%d\t%d\t%s\n", entry.Action, entry.FileName, entry.UploadBytes, entry.DownloadBytes, note)
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "%d uploads (%d bytes), %d downloads (%d bytes), %d remote deletions, %d local deletions, %d conflicts, %d skipped\n",
+		plan.Uploads, plan.UploadBytes, plan.Downloads, plan.DownloadBytes,
+		plan.RemoteDeletions, plan.LocalDeletions, plan.Conflicts, plan.Skipped)
 }
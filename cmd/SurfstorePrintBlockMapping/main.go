@@ -70,6 +70,7 @@ func main() {
 	}
 
 	rpcClient := surfstore.NewSurfstoreRPCClient(hostPort, baseDir, blockSize)
+	defer rpcClient.Close()
 	PrintBlocksOnEachServer(rpcClient)
 }
 
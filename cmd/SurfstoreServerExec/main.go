@@ -15,10 +15,10 @@ import (
 )
 
 // Usage String
-const USAGE_STRING = "./run-server.sh -s <service_type> -p <port> -l -d (blockStoreAddr*)"
+const USAGE_STRING = "./run-server.sh -s <service_type> -p <port> -l -d -e <blockEngine> -b <blockPath> -m <metaDir> -i <raftId> -r <raftPeers> (blockStoreAddr*)"
 
 // Set of valid services
-var SERVICE_TYPES = map[string]bool{"meta": true, "block": true, "both": true}
+var SERVICE_TYPES = map[string]bool{"meta": true, "block": true, "both": true, "raft": true}
 
 // Exit codes
 const EX_USAGE int = 64
@@ -35,10 +35,16 @@ func main() {
 	}
 
 	// Parse command-line argument flags
-	service := flag.String("s", "", "(required) Service Type of the Server: meta, block, both")
+	service := flag.String("s", "", "(required) Service Type of the Server: meta, block, both, raft")
 	port := flag.Int("p", 8080, "(default = 8080) Port to accept connections")
 	localOnly := flag.Bool("l", false, "Only listen on localhost")
 	debug := flag.Bool("d", false, "Output log statements")
+	blockEngine := flag.String("e", "", "Block storage engine: memory, file, sqlite (default = file if -b is set, else memory)")
+	blockPath := flag.String("b", "", "Block directory for the file engine or database file for the sqlite engine")
+	metaDir := flag.String("m", "", "Directory for the MetaStore write-ahead log and snapshots (default = keep metadata in memory)")
+	snapshotInterval := flag.Int("snapshot", surfstore.DEFAULT_SNAPSHOT_INTERVAL, "Number of logged updates between MetaStore snapshots, or of applied entries between raft snapshots")
+	raftId := flag.Int64("i", 0, "Index of this server in the raft peer list")
+	raftPeers := flag.String("r", "", "Comma-separated addresses of all raft MetaStore replicas, including this one")
 	flag.Parse()
 
 	// Use tail arguments to hold BlockStore address
@@ -67,19 +73,38 @@ func main() {
 		log.SetOutput(io.Discard)
 	}
 
-	log.Fatal(startServer(addr, strings.ToLower(*service), blockStoreAddrs))
+	if strings.ToLower(*service) == "raft" {
+		log.Fatal(startRaftServer(addr, *raftId, *raftPeers, blockStoreAddrs, *metaDir, *snapshotInterval))
+	}
+	log.Fatal(startServer(addr, strings.ToLower(*service), blockStoreAddrs, strings.ToLower(*blockEngine), *blockPath, *metaDir, *snapshotInterval))
 }
 
-func startServer(hostAddr string, serviceType string, blockStoreAddrs []string) error {
+func startServer(hostAddr string, serviceType string, blockStoreAddrs []string, blockEngine string, blockPath string, metaDir string, snapshotInterval int) error {
 	fmt.Println("start server")
-	grpcServer := grpc.NewServer()
+	grpcServer := grpc.NewServer(surfstore.ServerKeepaliveOptions()...)
 	if serviceType == "block" {
-		surfstore.RegisterBlockStoreServer(grpcServer, surfstore.NewBlockStore())
+		blockStore, err := newBlockStore(blockEngine, blockPath)
+		if err != nil {
+			return err
+		}
+		surfstore.RegisterBlockStoreServer(grpcServer, blockStore)
 	} else if serviceType == "meta" {
-		surfstore.RegisterMetaStoreServer(grpcServer, surfstore.NewMetaStore(blockStoreAddrs))
+		metaStore, err := newMetaStore(blockStoreAddrs, metaDir, snapshotInterval)
+		if err != nil {
+			return err
+		}
+		surfstore.RegisterMetaStoreServer(grpcServer, metaStore)
 	} else if serviceType == "both" {
-		surfstore.RegisterBlockStoreServer(grpcServer, surfstore.NewBlockStore())
-		surfstore.RegisterMetaStoreServer(grpcServer, surfstore.NewMetaStore(blockStoreAddrs))
+		blockStore, err := newBlockStore(blockEngine, blockPath)
+		if err != nil {
+			return err
+		}
+		metaStore, err := newMetaStore(blockStoreAddrs, metaDir, snapshotInterval)
+		if err != nil {
+			return err
+		}
+		surfstore.RegisterBlockStoreServer(grpcServer, blockStore)
+		surfstore.RegisterMetaStoreServer(grpcServer, metaStore)
 	} else {
 		return fmt.Errorf("Invalid service type: %s", serviceType)
 	}
@@ -91,3 +116,50 @@ func startServer(hostAddr string, serviceType string, blockStoreAddrs []string)
 
 	return grpcServer.Serve(ln)
 }
+
+// startRaftServer serves one replica of a Raft replicated MetaStore. metaDir,
+// when set, keeps the replica's term, vote, log and snapshots across restarts.
+func startRaftServer(hostAddr string, raftId int64, raftPeers string, blockStoreAddrs []string, metaDir string, snapshotInterval int) error {
+	peers := strings.Split(raftPeers, surfstore.CONFIG_DELIMITER)
+	if raftPeers == "" || raftId < 0 || raftId >= int64(len(peers)) {
+		return fmt.Errorf("raft server %d is not in peer list %q", raftId, raftPeers)
+	}
+	server, err := surfstore.NewRaftServer(raftId, peers, blockStoreAddrs, metaDir, snapshotInterval)
+	if err != nil {
+		return err
+	}
+	grpcServer := grpc.NewServer(surfstore.ServerKeepaliveOptions()...)
+	surfstore.RegisterRaftSurfstoreServer(grpcServer, server)
+	surfstore.RegisterMetaStoreServer(grpcServer, server)
+
+	ln, err := net.Listen("tcp", hostAddr)
+	if err != nil {
+		return fmt.Errorf("listen error: %v", err)
+	}
+	server.Start()
+	return grpcServer.Serve(ln)
+}
+
+// newBlockStore opens the requested block engine, picking the file engine
+// when only a block path is given
+func newBlockStore(blockEngine string, blockPath string) (surfstore.BlockStoreServer, error) {
+	if blockEngine == "" {
+		if blockPath != "" {
+			return surfstore.NewDiskBlockStore(blockPath)
+		}
+		return surfstore.NewBlockStore(), nil
+	}
+	backend, err := surfstore.NewBlockBackend(blockEngine, blockPath)
+	if err != nil {
+		return nil, err
+	}
+	return surfstore.NewBlockStoreWithBackend(backend), nil
+}
+
+// newMetaStore recovers a durable MetaStore when a meta directory is given
+func newMetaStore(blockStoreAddrs []string, metaDir string, snapshotInterval int) (*surfstore.MetaStore, error) {
+	if metaDir == "" {
+		return surfstore.NewMetaStore(blockStoreAddrs), nil
+	}
+	return surfstore.NewDurableMetaStore(blockStoreAddrs, metaDir, snapshotInterval)
+}
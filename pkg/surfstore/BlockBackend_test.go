@@ -0,0 +1,142 @@
+package surfstore
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// blockEngines opens every BlockBackend in dir. persistent engines must
+// find their blocks again when reopened on the same dir.
+var blockEngines = []struct {
+	name       string
+	persistent bool
+	open       func(dir string) (BlockBackend, error)
+}{
+	{BLOCK_ENGINE_MEMORY, false, func(dir string) (BlockBackend, error) {
+		return NewMemoryBlockBackend(), nil
+	}},
+	{BLOCK_ENGINE_FILE, true, func(dir string) (BlockBackend, error) {
+		return NewFileBlockBackend(dir)
+	}},
+	{BLOCK_ENGINE_SQLITE, true, func(dir string) (BlockBackend, error) {
+		return NewSQLiteBlockBackend(filepath.Join(dir, "blocks.db"))
+	}},
+}
+
+func testBlock(i int) (string, *Block) {
+	blockData := []byte(fmt.Sprintf("block %d", i))
+	return GetBlockHashString(blockData), &Block{BlockData: blockData, BlockSize: int32(len(blockData))}
+}
+
+func openBlockBackend(t *testing.T, open func(string) (BlockBackend, error), dir string) BlockBackend {
+	t.Helper()
+	backend, err := open(dir)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { backend.Close() })
+	return backend
+}
+
+func TestBlockBackendConformance(t *testing.T) {
+	for _, engine := range blockEngines {
+		t.Run(engine.name, func(t *testing.T) {
+			dir := t.TempDir()
+			backend := openBlockBackend(t, engine.open, dir)
+
+			missingHash, _ := testBlock(-1)
+			if _, err := backend.Get(missingHash); !errors.Is(err, ErrBlockNotFound) {
+				t.Fatalf("Get of a missing block: got %v, want ErrBlockNotFound", err)
+			}
+			if ok, err := backend.Has(missingHash); err != nil || ok {
+				t.Fatalf("Has of a missing block: got %v, %v", ok, err)
+			}
+
+			want := []string{}
+			for i := 0; i < 10; i++ {
+				hash, block := testBlock(i)
+				if err := backend.Put(hash, block); err != nil {
+					t.Fatalf("Put: %v", err)
+				}
+				want = append(want, hash)
+			}
+			// blocks are content addressed, putting one again changes nothing
+			hash, block := testBlock(0)
+			if err := backend.Put(hash, block); err != nil {
+				t.Fatalf("Put again: %v", err)
+			}
+
+			check := func(backend BlockBackend) {
+				t.Helper()
+				for i := 0; i < 10; i++ {
+					hash, block := testBlock(i)
+					got, err := backend.Get(hash)
+					if err != nil {
+						t.Fatalf("Get %d: %v", i, err)
+					}
+					if !bytes.Equal(got.GetBlockData(), block.GetBlockData()) || got.GetBlockSize() != block.GetBlockSize() {
+						t.Fatalf("Get %d: got %q (%d bytes), want %q", i, got.GetBlockData(), got.GetBlockSize(), block.GetBlockData())
+					}
+					if ok, err := backend.Has(hash); err != nil || !ok {
+						t.Fatalf("Has %d: got %v, %v", i, ok, err)
+					}
+				}
+				hashes, err := backend.Hashes()
+				if err != nil {
+					t.Fatalf("Hashes: %v", err)
+				}
+				slices.Sort(hashes)
+				slices.Sort(want)
+				if !slices.Equal(hashes, want) {
+					t.Fatalf("Hashes: got %v, want %v", hashes, want)
+				}
+			}
+			check(backend)
+
+			if !engine.persistent {
+				return
+			}
+			if err := backend.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+			check(openBlockBackend(t, engine.open, dir))
+		})
+	}
+}
+
+func TestFileBlockBackendRejectsInvalidHashes(t *testing.T) {
+	backend, err := NewFileBlockBackend(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, block := testBlock(0)
+	for _, invalid := range []string{
+		"",
+		"ab",
+		"../../../../etc/passwd",
+		"../" + hash[3:],
+		hash[:len(hash)-1] + "g",
+		hash + "00",
+		"/" + hash[1:],
+	} {
+		if _, err := backend.blockPath(invalid); err == nil {
+			t.Errorf("blockPath(%q) was accepted", invalid)
+		}
+		if err := backend.Put(invalid, block); err == nil {
+			t.Errorf("Put(%q) was accepted", invalid)
+		}
+		if _, err := backend.Get(invalid); err == nil {
+			t.Errorf("Get(%q) was accepted", invalid)
+		}
+		if ok, _ := backend.Has(invalid); ok {
+			t.Errorf("Has(%q) reported a block", invalid)
+		}
+	}
+	if _, err := backend.blockPath(hash); err != nil {
+		t.Errorf("blockPath of a valid hash: %v", err)
+	}
+}
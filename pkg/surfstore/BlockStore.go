@@ -2,27 +2,34 @@ package surfstore
 
 import (
 	context "context"
+	"errors"
 	"fmt"
 
 	emptypb "google.golang.org/protobuf/types/known/emptypb"
 )
 
+var ErrBlockNotFound = errors.New("block not found")
+
 type BlockStore struct {
-	BlockMap map[string]*Block
+	Backend BlockBackend
 	UnimplementedBlockStoreServer
 }
 
 func (bs *BlockStore) GetBlock(ctx context.Context, blockHash *BlockHash) (*Block, error) {
-	if block, ok := bs.BlockMap[blockHash.GetHash()]; ok {
-		return block, nil
-	} else {
+	block, err := bs.Backend.Get(blockHash.GetHash())
+	if errors.Is(err, ErrBlockNotFound) {
 		return nil, fmt.Errorf("Block with hash %s not found", blockHash.GetHash())
+	} else if err != nil {
+		return nil, err
 	}
+	return block, nil
 }
 
 func (bs *BlockStore) PutBlock(ctx context.Context, block *Block) (*Success, error) {
 	index := GetBlockHashString(block.GetBlockData())
-	bs.BlockMap[index] = block
+	if err := bs.Backend.Put(index, block); err != nil {
+		return nil, err
+	}
 	return &Success{Flag: true}, nil
 }
 
@@ -31,7 +38,11 @@ func (bs *BlockStore) PutBlock(ctx context.Context, block *Block) (*Success, err
 func (bs *BlockStore) MissingBlocks(ctx context.Context, blockHashesIn *BlockHashes) (*BlockHashes, error) {
 	blockHashesOut := &BlockHashes{}
 	for _, hash := range blockHashesIn.GetHashes() {
-		if _, ok := bs.BlockMap[hash]; !ok {
+		ok, err := bs.Backend.Has(hash)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
 			blockHashesOut.Hashes = append(blockHashesOut.Hashes, hash)
 		}
 	}
@@ -40,18 +51,47 @@ func (bs *BlockStore) MissingBlocks(ctx context.Context, blockHashesIn *BlockHas
 
 // Return a list containing all blockHashes on this block server
 func (bs *BlockStore) GetBlockHashes(ctx context.Context, _ *emptypb.Empty) (*BlockHashes, error) {
-	allBlockHashes := &BlockHashes{}
-	for hash := range bs.BlockMap {
-		allBlockHashes.Hashes = append(allBlockHashes.Hashes, hash)
+	hashes, err := bs.Backend.Hashes()
+	if err != nil {
+		return nil, err
 	}
-	return allBlockHashes, nil
+	return &BlockHashes{Hashes: hashes}, nil
 }
 
 // This line guarantees all method for BlockStore are implemented
 var _ BlockStoreInterface = new(BlockStore)
 
 func NewBlockStore() *BlockStore {
+	return NewBlockStoreWithBackend(NewMemoryBlockBackend())
+}
+
+// NewDiskBlockStore keeps blocks as files under baseDir, so they survive
+// restarts. It is the file engine that -b selects.
+func NewDiskBlockStore(baseDir string) (*BlockStore, error) {
+	backend, err := NewFileBlockBackend(baseDir)
+	if err != nil {
+		return nil, err
+	}
+	return NewBlockStoreWithBackend(backend), nil
+}
+
+func NewBlockStoreWithBackend(backend BlockBackend) *BlockStore {
 	return &BlockStore{
-		BlockMap: map[string]*Block{},
+		Backend: backend,
+	}
+}
+
+// NewBlockBackend opens the storage engine named by engine. path is the
+// block directory for the file engine and the database file for sqlite.
+func NewBlockBackend(engine string, path string) (BlockBackend, error) {
+	switch engine {
+	case BLOCK_ENGINE_MEMORY:
+		return NewMemoryBlockBackend(), nil
+	case BLOCK_ENGINE_FILE:
+		return NewFileBlockBackend(path)
+	case BLOCK_ENGINE_SQLITE:
+		return NewSQLiteBlockBackend(path)
+	default:
+		return nil, fmt.Errorf("Invalid block engine: %s", engine)
 	}
 }
@@ -0,0 +1,68 @@
+package surfstore
+
+import (
+	"bytes"
+	context "context"
+	"sync"
+	"testing"
+)
+
+// TestBlockStoreConcurrentPutGet hammers every engine with writers and
+// readers of overlapping blocks. A block must read back whole once its
+// PutBlock returned, and never torn while it is being written again.
+func TestBlockStoreConcurrentPutGet(t *testing.T) {
+	const workers = 8
+	const blocks = 64
+	const rounds = 20
+	for _, engine := range blockEngines {
+		t.Run(engine.name, func(t *testing.T) {
+			bs := NewBlockStoreWithBackend(openBlockBackend(t, engine.open, t.TempDir()))
+			ctx := context.Background()
+
+			var wg sync.WaitGroup
+			for w := 0; w < workers; w++ {
+				wg.Add(1)
+				go func(w int) {
+					defer wg.Done()
+					for r := 0; r < rounds; r++ {
+						for i := 0; i < blocks; i++ {
+							// workers put the same blocks in different orders
+							hash, block := testBlock((i*(w+1) + r) % blocks)
+							if _, err := bs.PutBlock(ctx, block); err != nil {
+								t.Errorf("PutBlock: %v", err)
+								return
+							}
+							got, err := bs.GetBlock(ctx, &BlockHash{Hash: hash})
+							if err != nil {
+								t.Errorf("GetBlock after PutBlock: %v", err)
+								return
+							}
+							if !bytes.Equal(got.GetBlockData(), block.GetBlockData()) {
+								t.Errorf("GetBlock %s: got %q, want %q", hash, got.GetBlockData(), block.GetBlockData())
+								return
+							}
+						}
+					}
+				}(w)
+			}
+			wg.Wait()
+
+			hashes, err := bs.GetBlockHashes(ctx, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(hashes.GetHashes()) != blocks {
+				t.Fatalf("GetBlockHashes: got %d hashes, want %d", len(hashes.GetHashes()), blocks)
+			}
+			all := []string{}
+			for i := 0; i < blocks; i++ {
+				hash, _ := testBlock(i)
+				all = append(all, hash)
+			}
+			missing, err := bs.MissingBlocks(ctx, &BlockHashes{Hashes: all})
+			if err != nil || len(missing.GetHashes()) != 0 {
+				t.Fatalf("MissingBlocks: got %v, %v", missing.GetHashes(), err)
+			}
+		})
+	}
+}
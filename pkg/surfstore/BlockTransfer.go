@@ -0,0 +1,43 @@
+package surfstore
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// transferBlocks runs transfer(0) ... transfer(n-1) on at most workers
+// goroutines. gRPC multiplexes the calls over the pooled connections, so
+// blocks on different BlockStores, and on the same one, move concurrently.
+// After the first failure, or once ctx is canceled, no new transfers start
+// and that error is returned once the running ones finished.
+func transferBlocks(ctx context.Context, workers int, n int, transfer func(i int) error) error {
+	workers = max(min(workers, n), 1)
+	var next atomic.Int64
+	var failed atomic.Bool
+	var once sync.Once
+	var firstErr error
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for !failed.Load() {
+				i := int(next.Add(1) - 1)
+				if i >= n {
+					return
+				}
+				err := ctx.Err()
+				if err == nil {
+					err = transfer(i)
+				}
+				if err != nil {
+					once.Do(func() { firstErr = err })
+					failed.Store(true)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return firstErr
+}
@@ -0,0 +1,156 @@
+package surfstore
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// HashedBlock is one block of a scanned file
+type HashedBlock struct {
+	Hash   string
+	Offset int64
+	Size   int
+}
+
+// FixedChunker cuts files into blocks of BlockSize bytes
+type FixedChunker struct {
+	BlockSize int
+}
+
+func (fc *FixedChunker) Scheme() string {
+	return CHUNKING_FIXED
+}
+
+func (fc *FixedChunker) Describe(fileMetaData *FileMetaData) {
+	fileMetaData.ChunkingScheme = CHUNKING_FIXED
+	fileMetaData.BlockSize = int32(fc.BlockSize)
+	fileMetaData.MinChunkSize = 0
+	fileMetaData.MaxChunkSize = 0
+}
+
+func (fc *FixedChunker) Chunk(r io.Reader, workers int) ([]HashedBlock, error) {
+	return hashChunks(r, workers, fc.BlockSize, func(data []byte) int {
+		return len(data)
+	})
+}
+
+// This line guarantees all method for FixedChunker are implemented
+var _ Chunker = new(FixedChunker)
+
+func NewFixedChunker(blockSize int) (*FixedChunker, error) {
+	if blockSize <= 0 {
+		return nil, fmt.Errorf("block size must be positive, got %d", blockSize)
+	}
+	return &FixedChunker{BlockSize: blockSize}, nil
+}
+
+// ChunkerFor rebuilds the chunker that cut a file's hash list. Metadata
+// written before chunking parameters were recorded comes from fixed blocks
+// of an unknown size, defaultBlockSize is assumed for it.
+func ChunkerFor(fileMetaData *FileMetaData, defaultBlockSize int) (Chunker, error) {
+	blockSize := int(fileMetaData.GetBlockSize())
+	if blockSize == 0 {
+		blockSize = defaultBlockSize
+	}
+	switch fileMetaData.GetChunkingScheme() {
+	case "", CHUNKING_FIXED:
+		return NewFixedChunker(blockSize)
+	case CHUNKING_FASTCDC:
+		return NewFastCDCChunker(int(fileMetaData.GetMinChunkSize()), blockSize, int(fileMetaData.GetMaxChunkSize()))
+	default:
+		return nil, fmt.Errorf("unknown chunking scheme %q", fileMetaData.GetChunkingScheme())
+	}
+}
+
+// SameChunking reports whether chunker cuts files exactly like the chunker
+// that produced a file's recorded hash list, so the lists can be compared
+func SameChunking(chunker Chunker, fileMetaData *FileMetaData, defaultBlockSize int) bool {
+	other, err := ChunkerFor(fileMetaData, defaultBlockSize)
+	if err != nil {
+		return false
+	}
+	a, b := &FileMetaData{}, &FileMetaData{}
+	chunker.Describe(a)
+	other.Describe(b)
+	return a.ChunkingScheme == b.ChunkingScheme && a.BlockSize == b.BlockSize &&
+		a.MinChunkSize == b.MinChunkSize && a.MaxChunkSize == b.MaxChunkSize
+}
+
+// hashChunks splits r into blocks and returns them in order. cut is shown
+// the next maxSize bytes of the input, fewer only at its end, and returns
+// the length of the next block. One goroutine cuts while workers hash in
+// parallel. Buffers are recycled from a pool of two per worker, so memory
+// stays bounded however large the input is. An empty input yields no blocks.
+func hashChunks(r io.Reader, workers int, maxSize int, cut func(data []byte) int) ([]HashedBlock, error) {
+	workers = max(workers, 1)
+	type hashJob struct {
+		index int
+		data  []byte
+	}
+	maxBuffers := 2 * workers
+	buffers := make(chan []byte, maxBuffers)
+	jobs := make(chan hashJob)
+
+	var mu sync.Mutex
+	blocks := []HashedBlock{}
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				hash := GetBlockHashString(job.data)
+				mu.Lock()
+				blocks[job.index].Hash = hash
+				mu.Unlock()
+				buffers <- job.data[:cap(job.data)]
+			}
+		}()
+	}
+
+	reader := bufio.NewReaderSize(r, maxSize)
+	var readErr error
+	var offset int64
+	allocated := 0
+	for index := 0; ; index++ {
+		data, err := reader.Peek(maxSize)
+		if len(data) == 0 {
+			if err != io.EOF {
+				readErr = err
+			}
+			break
+		} else if err != nil && err != io.EOF {
+			readErr = err
+			break
+		}
+		n := cut(data)
+
+		var buf []byte
+		select {
+		case buf = <-buffers:
+		default:
+			if allocated < maxBuffers {
+				buf = make([]byte, maxSize)
+				allocated++
+			} else {
+				buf = <-buffers
+			}
+		}
+		buf = buf[:copy(buf, data[:n])]
+		reader.Discard(n)
+
+		mu.Lock()
+		blocks = append(blocks, HashedBlock{Offset: offset, Size: n})
+		mu.Unlock()
+		jobs <- hashJob{index: index, data: buf}
+		offset += int64(n)
+	}
+	close(jobs)
+	wg.Wait()
+	if readErr != nil {
+		return nil, readErr
+	}
+	return blocks, nil
+}
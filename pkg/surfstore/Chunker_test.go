@@ -0,0 +1,164 @@
+package surfstore
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func randomData(seed int64, size int) []byte {
+	data := make([]byte, size)
+	rand.New(rand.NewSource(seed)).Read(data)
+	return data
+}
+
+func chunk(t *testing.T, chunker Chunker, data []byte, workers int) []HashedBlock {
+	t.Helper()
+	blocks, err := chunker.Chunk(bytes.NewReader(data), workers)
+	if err != nil {
+		t.Fatalf("Chunk: %v", err)
+	}
+	return blocks
+}
+
+func newTestFastCDCChunker(t *testing.T) *FastCDCChunker {
+	t.Helper()
+	chunker, err := NewFastCDCChunker(0, 4096, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return chunker
+}
+
+// TestChunkersReturnBlocksInFileOrder checks that the blocks hashed in
+// parallel come back in file order, covering the input without gaps, each
+// with the hash of its own bytes
+func TestChunkersReturnBlocksInFileOrder(t *testing.T) {
+	data := randomData(1, 1<<20+123)
+	for _, chunker := range []Chunker{&FixedChunker{BlockSize: 4096}, newTestFastCDCChunker(t)} {
+		t.Run(chunker.Scheme(), func(t *testing.T) {
+			for _, workers := range []int{1, 3, 16} {
+				var offset int64
+				for i, block := range chunk(t, chunker, data, workers) {
+					if block.Offset != offset {
+						t.Fatalf("%d workers: block %d starts at %d, want %d", workers, i, block.Offset, offset)
+					}
+					if want := GetBlockHashString(data[offset : offset+int64(block.Size)]); block.Hash != want {
+						t.Fatalf("%d workers: block %d has hash %s, want %s", workers, i, block.Hash, want)
+					}
+					offset += int64(block.Size)
+				}
+				if offset != int64(len(data)) {
+					t.Fatalf("%d workers: blocks cover %d bytes, want %d", workers, offset, len(data))
+				}
+			}
+			if blocks := chunk(t, chunker, nil, 4); len(blocks) != 0 {
+				t.Fatalf("an empty file has blocks %v", blocks)
+			}
+		})
+	}
+}
+
+func TestFastCDCChunkerIsDeterministic(t *testing.T) {
+	data := randomData(2, 1<<20)
+	first := chunk(t, newTestFastCDCChunker(t), data, 1)
+	for _, workers := range []int{1, 8} {
+		again := chunk(t, newTestFastCDCChunker(t), data, workers)
+		if len(again) != len(first) {
+			t.Fatalf("%d workers: got %d blocks, want %d", workers, len(again), len(first))
+		}
+		for i := range first {
+			if again[i] != first[i] {
+				t.Fatalf("%d workers: block %d is %v, want %v", workers, i, again[i], first[i])
+			}
+		}
+	}
+}
+
+func TestFastCDCChunkerRespectsSizes(t *testing.T) {
+	for _, sizes := range [][3]int{{1024, 4096, 16384}, {256, 1024, 2048}, {4096, 4096, 4096}} {
+		chunker, err := NewFastCDCChunker(sizes[0], sizes[1], sizes[2])
+		if err != nil {
+			t.Fatal(err)
+		}
+		data := randomData(3, 4<<20)
+		blocks := chunk(t, chunker, data, 4)
+		for i, block := range blocks {
+			if block.Size > chunker.MaxSize || (block.Size < chunker.MinSize && i != len(blocks)-1) {
+				t.Fatalf("%v: block %d has %d bytes", sizes, i, block.Size)
+			}
+		}
+		if avg := len(data) / len(blocks); avg < chunker.AvgSize/2 || avg > chunker.AvgSize*2 {
+			t.Fatalf("%v: blocks average %d bytes, want about %d", sizes, avg, chunker.AvgSize)
+		}
+	}
+	if _, err := NewFastCDCChunker(2048, 1024, 4096); err == nil {
+		t.Fatal("NewFastCDCChunker accepted a minimum above the average")
+	}
+}
+
+// an insertion in the middle of a file only changes the blocks around it
+func TestFastCDCChunkerReusesBlocksAfterInsertion(t *testing.T) {
+	data := randomData(4, 4<<20)
+	edited := append(append(append([]byte{}, data[:len(data)/2]...), "a few bytes"...), data[len(data)/2:]...)
+
+	chunker := newTestFastCDCChunker(t)
+	before := make(map[string]bool)
+	for _, block := range chunk(t, chunker, data, 4) {
+		before[block.Hash] = true
+	}
+	after := chunk(t, chunker, edited, 4)
+	changed := 0
+	for _, block := range after {
+		if !before[block.Hash] {
+			changed++
+		}
+	}
+	if changed == 0 || changed > 3 {
+		t.Fatalf("%d of %d blocks changed, want only the ones around the insertion", changed, len(after))
+	}
+
+	// fixed blocks all shift after the insertion
+	fixed := &FixedChunker{BlockSize: 4096}
+	before = make(map[string]bool)
+	for _, block := range chunk(t, fixed, data, 4) {
+		before[block.Hash] = true
+	}
+	changed = 0
+	for _, block := range chunk(t, fixed, edited, 4) {
+		if !before[block.Hash] {
+			changed++
+		}
+	}
+	if changed < len(data)/4096/2 {
+		t.Fatalf("only %d fixed blocks changed", changed)
+	}
+}
+
+func TestSameChunking(t *testing.T) {
+	fastCDC := newTestFastCDCChunker(t)
+	describe := func(chunker Chunker) *FileMetaData {
+		fileMetaData := &FileMetaData{}
+		chunker.Describe(fileMetaData)
+		return fileMetaData
+	}
+	for _, tc := range []struct {
+		name         string
+		chunker      Chunker
+		fileMetaData *FileMetaData
+		same         bool
+	}{
+		{"same block size", &FixedChunker{BlockSize: 4096}, describe(&FixedChunker{BlockSize: 4096}), true},
+		{"other block size", &FixedChunker{BlockSize: 4096}, describe(&FixedChunker{BlockSize: 1024}), false},
+		{"unrecorded chunking", &FixedChunker{BlockSize: 4096}, &FileMetaData{}, true},
+		{"unrecorded chunking, other default", &FixedChunker{BlockSize: 1024}, &FileMetaData{}, false},
+		{"same fastcdc sizes", fastCDC, describe(newTestFastCDCChunker(t)), true},
+		{"other fastcdc sizes", fastCDC, &FileMetaData{ChunkingScheme: CHUNKING_FASTCDC, BlockSize: 4096, MinChunkSize: 512, MaxChunkSize: 16384}, false},
+		{"fastcdc and fixed", fastCDC, describe(&FixedChunker{BlockSize: 4096}), false},
+		{"unknown scheme", &FixedChunker{BlockSize: 4096}, &FileMetaData{ChunkingScheme: "other", BlockSize: 4096}, false},
+	} {
+		if got := SameChunking(tc.chunker, tc.fileMetaData, 4096); got != tc.same {
+			t.Errorf("%s: SameChunking is %v, want %v", tc.name, got, tc.same)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package surfstore
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// ErrVersionConflict is returned by Push when the MetaStore refused a version
+// because another client committed a newer version of the file first
+var ErrVersionConflict = fmt.Errorf("version conflict")
+
+// Conflict is a file that was changed locally and on the server at once.
+// With KeepBoth the local version was kept as ConflictCopy. A merged file
+// holds Overlaps hunks changed on both sides between conflict markers.
+type Conflict struct {
+	FileName     string
+	ConflictCopy string
+	Resolution   Resolution
+	Merged       bool
+	Overlaps     int
+}
+
+// ConflictCopyName names the copy that keeps the losing local version of a
+// file, e.g. "report (conflicted copy from host 2026-10-18).txt". Names for
+// which taken reports true get a number added. The copy stays a valid
+// filename: the name is shortened if the suffix makes it too long.
+func ConflictCopyName(fileName string, host string, when time.Time, taken func(string) bool) string {
+	dir, base := path.Split(fileName)
+	ext := path.Ext(base)
+	stem := strings.TrimSuffix(base, ext)
+	if stem == "" {
+		// a dotfile such as .bashrc has no extension
+		stem, ext = base, ""
+	}
+	label := fmt.Sprintf("conflicted copy from %s %s", host, when.Format(time.DateOnly))
+	for n := 1; ; n++ {
+		suffix := " (" + label + ")"
+		if n > 1 {
+			suffix = fmt.Sprintf(" (%s %d)", label, n)
+		}
+		trimmed := stem
+		for len(trimmed)+len(suffix)+len(ext) > MAX_FILENAME_SEGMENT_LENGTH && trimmed != "" {
+			_, size := utf8.DecodeLastRuneInString(trimmed)
+			trimmed = trimmed[:len(trimmed)-size]
+		}
+		name := dir + trimmed + suffix + ext
+		if !taken(name) {
+			return name
+		}
+	}
+}
+
+// saveConflictCopy moves the local version of a file that lost a concurrent
+// edit out of the way and uploads it as a new file. lost is the version the
+// client failed to push. The copy's metadata is returned.
+func saveConflictCopy(ctx context.Context, client ClientInterface, opts SyncOptions, lost *FileMetaData, copyName string, localBlocks LocalBlockIndex, stats *SyncStats) (*FileMetaData, error) {
+	oldPath := ConcatPath(opts.BaseDir, lost.GetFilename())
+	newPath := ConcatPath(opts.BaseDir, copyName)
+	if err := os.Rename(oldPath, newPath); err != nil {
+		return nil, err
+	}
+	localBlocks.Move(oldPath, newPath)
+
+	conflictCopy := proto.Clone(lost).(*FileMetaData)
+	conflictCopy.Filename = copyName
+	conflictCopy.Version = 1
+	blockStoreMap := make(map[string][]string)
+	if err := client.GetBlockStoreMap(conflictCopy.GetBlockHashList(), &blockStoreMap); err != nil {
+		return nil, err
+	}
+	if err := Push(ctx, client, opts, conflictCopy, localBlocks, blockStoreMap, stats); err != nil {
+		return nil, err
+	}
+	stats.addConflict(lost.GetFilename(), copyName, KeepBoth)
+	return conflictCopy, nil
+}
+
+func conflictHost() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		return "unknown host"
+	}
+	return host
+}
@@ -0,0 +1,111 @@
+package surfstore
+
+import (
+	"fmt"
+)
+
+// Resolution is how a conflict on a file is settled
+type Resolution int
+
+const (
+	// pull the server's version over the local one
+	KeepRemote Resolution = iota
+	// commit the local version on top of the server's one
+	KeepLocal
+	// keep the local version as a conflict copy, then pull the server's one
+	KeepBoth
+)
+
+var resolutionNames = []string{"keep-remote", "keep-local", "keep-both"}
+
+func (resolution Resolution) String() string {
+	if resolution < 0 || int(resolution) >= len(resolutionNames) {
+		return fmt.Sprintf("Resolution(%d)", int(resolution))
+	}
+	return resolutionNames[resolution]
+}
+
+// ServerWinsResolver discards local changes that lost the race
+type ServerWinsResolver struct{}
+
+func (r *ServerWinsResolver) Policy() string {
+	return CONFLICT_SERVER_WINS
+}
+
+func (r *ServerWinsResolver) Resolve(fileName string, base, local, remote *FileMetaData) Resolution {
+	return KeepRemote
+}
+
+// This line guarantees all method for ServerWinsResolver are implemented
+var _ ConflictResolver = new(ServerWinsResolver)
+
+// ClientWinsResolver overwrites the server's version with the local one
+type ClientWinsResolver struct{}
+
+func (r *ClientWinsResolver) Policy() string {
+	return CONFLICT_CLIENT_WINS
+}
+
+func (r *ClientWinsResolver) Resolve(fileName string, base, local, remote *FileMetaData) Resolution {
+	return KeepLocal
+}
+
+// This line guarantees all method for ClientWinsResolver are implemented
+var _ ConflictResolver = new(ClientWinsResolver)
+
+// NewestWinsResolver keeps the version modified last. A deletion counts as
+// made when the server committed it. When a time is unknown, e.g. for a
+// local deletion or a version of a client that did not record mtimes, both
+// versions are kept.
+type NewestWinsResolver struct{}
+
+func (r *NewestWinsResolver) Policy() string {
+	return CONFLICT_NEWEST_WINS
+}
+
+func (r *NewestWinsResolver) Resolve(fileName string, base, local, remote *FileMetaData) Resolution {
+	remoteTime := remote.GetMtime()
+	if IsTombstone(remote) {
+		remoteTime = remote.GetCommitTime()
+	}
+	if IsTombstone(local) || local.GetMtime() == 0 || remoteTime == 0 {
+		return KeepBoth
+	}
+	if local.GetMtime() > remoteTime {
+		return KeepLocal
+	}
+	return KeepRemote
+}
+
+// This line guarantees all method for NewestWinsResolver are implemented
+var _ ConflictResolver = new(NewestWinsResolver)
+
+// KeepBothResolver keeps the local version as a conflict copy
+type KeepBothResolver struct{}
+
+func (r *KeepBothResolver) Policy() string {
+	return CONFLICT_KEEP_BOTH
+}
+
+func (r *KeepBothResolver) Resolve(fileName string, base, local, remote *FileMetaData) Resolution {
+	return KeepBoth
+}
+
+// This line guarantees all method for KeepBothResolver are implemented
+var _ ConflictResolver = new(KeepBothResolver)
+
+// NewConflictResolver creates the resolver of a policy
+func NewConflictResolver(policy string) (ConflictResolver, error) {
+	switch policy {
+	case CONFLICT_SERVER_WINS:
+		return &ServerWinsResolver{}, nil
+	case CONFLICT_CLIENT_WINS:
+		return &ClientWinsResolver{}, nil
+	case CONFLICT_NEWEST_WINS:
+		return &NewestWinsResolver{}, nil
+	case CONFLICT_KEEP_BOTH:
+		return &KeepBothResolver{}, nil
+	default:
+		return nil, fmt.Errorf("unknown conflict policy %q", policy)
+	}
+}
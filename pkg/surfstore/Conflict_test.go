@@ -0,0 +1,102 @@
+package surfstore
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConflictCopyName(t *testing.T) {
+	when := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	const label = "conflicted copy from laptop 2026-10-18"
+	for _, tc := range []struct {
+		fileName string
+		taken    []string
+		want     string
+	}{
+		{"report.txt", nil, "report (" + label + ").txt"},
+		{"a.tar.gz", nil, "a.tar (" + label + ").gz"},
+		{".bashrc", nil, ".bashrc (" + label + ")"},
+		{"Makefile", nil, "Makefile (" + label + ")"},
+		{"docs/notes/plan.md", nil, "docs/notes/plan (" + label + ").md"},
+		{"v1.2/readme", nil, "v1.2/readme (" + label + ")"},
+		{"report.txt", []string{"report (" + label + ").txt"}, "report (" + label + " 2).txt"},
+		{"dir/.env", []string{"dir/.env (" + label + ")", "dir/.env (" + label + " 2)"}, "dir/.env (" + label + " 3)"},
+		// a copy in another directory is no collision
+		{"dir/report.txt", []string{"report (" + label + ").txt"}, "dir/report (" + label + ").txt"},
+	} {
+		taken := func(name string) bool {
+			for _, takenName := range tc.taken {
+				if name == takenName {
+					return true
+				}
+			}
+			return false
+		}
+		if got := ConflictCopyName(tc.fileName, "laptop", when, taken); got != tc.want {
+			t.Errorf("ConflictCopyName(%q) with %v taken = %q, want %q", tc.fileName, tc.taken, got, tc.want)
+		}
+	}
+
+	long := strings.Repeat("é", 125) + ".txt"
+	got := ConflictCopyName("dir/"+long, "laptop", when, func(string) bool { return false })
+	if err := ValidateFilename(got); err != nil || !strings.HasSuffix(got, " ("+label+").txt") {
+		t.Fatalf("ConflictCopyName of a long name = %q (%v)", got, err)
+	}
+}
+
+func TestSaveConflictCopy(t *testing.T) {
+	client := newFakeClient()
+	baseDir := t.TempDir()
+	writeTestFile(t, baseDir, "dir/a.txt", "local version\n")
+	localBlocks := make(LocalBlockIndex)
+	hashList, size, err := chunkLocalFile(ConcatPath(baseDir, "dir/a.txt"), &FixedChunker{BlockSize: 4}, localBlocks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lost := &FileMetaData{Filename: "dir/a.txt", Version: 2, BlockHashList: hashList, Size: size, Mode: 0644}
+
+	// an earlier conflict copy is in the way
+	when := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	firstCopy := ConflictCopyName("dir/a.txt", "laptop", when, func(string) bool { return false })
+	writeTestFile(t, baseDir, firstCopy, "an earlier conflict\n")
+	copyName := ConflictCopyName("dir/a.txt", "laptop", when, func(name string) bool {
+		_, err := os.Lstat(ConcatPath(baseDir, name))
+		return err == nil
+	})
+	if copyName != "dir/a (conflicted copy from laptop 2026-10-18 2).txt" {
+		t.Fatalf("the copy is named %q", copyName)
+	}
+
+	stats := &SyncStats{}
+	conflictCopy, err := saveConflictCopy(context.Background(), client, DefaultSyncOptions(baseDir, 4), lost, copyName, localBlocks, stats)
+	if err != nil {
+		t.Fatalf("saveConflictCopy: %v", err)
+	}
+	if _, err := os.Lstat(ConcatPath(baseDir, "dir/a.txt")); !os.IsNotExist(err) {
+		t.Fatalf("the local version was not moved: %v", err)
+	}
+	if got := readTestFile(t, baseDir, copyName); got != "local version\n" {
+		t.Fatalf("the copy holds %q", got)
+	}
+	if got := readTestFile(t, baseDir, firstCopy); got != "an earlier conflict\n" {
+		t.Fatalf("the earlier copy was overwritten with %q", got)
+	}
+
+	var fileInfoMap map[string]*FileMetaData
+	if err := client.GetFileInfoMap(&fileInfoMap); err != nil {
+		t.Fatal(err)
+	}
+	uploaded := fileInfoMap[copyName]
+	if uploaded.GetVersion() != 1 || conflictCopy.GetVersion() != 1 || strings.Join(FileBlockHashes(uploaded), ",") != strings.Join(hashList, ",") {
+		t.Fatalf("the copy was committed as %v", uploaded)
+	}
+	if _, ok := fileInfoMap["dir/a.txt"]; ok {
+		t.Fatal("saveConflictCopy committed the original file")
+	}
+	if len(stats.Conflicts) != 1 || stats.Conflicts[0] != (Conflict{FileName: "dir/a.txt", ConflictCopy: copyName, Resolution: KeepBoth}) {
+		t.Fatalf("saveConflictCopy recorded %v", stats.Conflicts)
+	}
+}
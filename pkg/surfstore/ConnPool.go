@@ -0,0 +1,110 @@
+package surfstore
+
+import (
+	"fmt"
+	"sync"
+
+	grpc "google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
+)
+
+// ConnPool keeps a few long-lived gRPC connections per server address and
+// hands them out round robin. gRPC multiplexes concurrent calls over each
+// connection and keepalive pings notice dead peers. A connection in
+// transient failure is told to reconnect right away, and one that was shut
+// down is replaced.
+type ConnPool struct {
+	Size int
+
+	mu     sync.Mutex
+	conns  map[string][]*grpc.ClientConn
+	next   map[string]int
+	closed bool
+}
+
+var ErrConnPoolClosed = fmt.Errorf("connection pool is closed")
+
+func (p *ConnPool) Get(addr string) (*grpc.ClientConn, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil, ErrConnPoolClosed
+	}
+	conns := p.conns[addr]
+	if len(conns) < p.Size {
+		conn, err := dialPooled(addr)
+		if err != nil {
+			return nil, err
+		}
+		p.conns[addr] = append(conns, conn)
+		return conn, nil
+	}
+
+	i := p.next[addr] % len(conns)
+	p.next[addr] = i + 1
+	conn := conns[i]
+	switch conn.GetState() {
+	case connectivity.Shutdown:
+		conn, err := dialPooled(addr)
+		if err != nil {
+			return nil, err
+		}
+		conns[i] = conn
+		return conn, nil
+	case connectivity.TransientFailure:
+		conn.ResetConnectBackoff()
+	}
+	return conn, nil
+}
+
+// Close closes every pooled connection, later calls to Get fail
+func (p *ConnPool) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	var firstErr error
+	for _, conns := range p.conns {
+		for _, conn := range conns {
+			if err := conn.Close(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	p.conns = make(map[string][]*grpc.ClientConn)
+	return firstErr
+}
+
+func dialPooled(addr string) (*grpc.ClientConn, error) {
+	return grpc.Dial(addr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                KEEPALIVE_TIME,
+			Timeout:             KEEPALIVE_TIMEOUT,
+			PermitWithoutStream: true,
+		}),
+	)
+}
+
+// ServerKeepaliveOptions lets servers accept the keepalive pings pooled
+// client connections send while idle
+func ServerKeepaliveOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             KEEPALIVE_TIME / 2,
+			PermitWithoutStream: true,
+		}),
+	}
+}
+
+func NewConnPool(size int) *ConnPool {
+	if size <= 0 {
+		size = DEFAULT_CONN_POOL_SIZE
+	}
+	return &ConnPool{
+		Size:  size,
+		conns: make(map[string][]*grpc.ClientConn),
+		next:  make(map[string]int),
+	}
+}
@@ -0,0 +1,86 @@
+package surfstore
+
+import (
+	context "context"
+	"net"
+	"sync"
+	"testing"
+
+	grpc "google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// startBlockStore serves a memory BlockStore holding block 0
+func startBlockStore(tb testing.TB) (string, string) {
+	tb.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	bs := NewBlockStore()
+	hash, block := testBlock(0)
+	if _, err := bs.PutBlock(context.Background(), block); err != nil {
+		tb.Fatal(err)
+	}
+	server := grpc.NewServer(ServerKeepaliveOptions()...)
+	RegisterBlockStoreServer(server, bs)
+	go server.Serve(ln)
+	tb.Cleanup(server.Stop)
+	return ln.Addr().String(), hash
+}
+
+// BenchmarkGetBlock compares GetBlock over pooled connections with dialing
+// a new connection for every call, as the client did before ConnPool
+func BenchmarkGetBlock(b *testing.B) {
+	addr, hash := startBlockStore(b)
+
+	b.Run("pooled", func(b *testing.B) {
+		client := NewSurfstoreRPCClient("", "", 4096)
+		defer client.Close()
+		var block Block
+		for i := 0; i < b.N; i++ {
+			if err := client.GetBlock(hash, addr, &block); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("dial", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+			if err != nil {
+				b.Fatal(err)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), DEFAULT_BLOCK_TIMEOUT)
+			_, err = NewBlockStoreClient(conn).GetBlock(ctx, &BlockHash{Hash: hash})
+			cancel()
+			conn.Close()
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// TestRPCClientWithoutPool runs concurrent calls on a client that was not
+// built by NewSurfstoreRPCClient, which must not race to create a pool
+func TestRPCClientWithoutPool(t *testing.T) {
+	addr, hash := startBlockStore(t)
+	client := RPCClient{Options: DefaultRPCOptions()}
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var block Block
+			if err := client.GetBlock(hash, addr, &block); err != nil {
+				t.Errorf("GetBlock: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if err := client.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,109 @@
+package surfstore
+
+import (
+	"fmt"
+	"io"
+	"math/bits"
+)
+
+// gearTable maps every byte to a pseudo-random 64-bit value. It must never
+// change: every client has to cut the same content at the same points.
+var gearTable = func() [256]uint64 {
+	var table [256]uint64
+	state := uint64(0x5375726653746f72)
+	for i := range table {
+		// splitmix64
+		state += 0x9e3779b97f4a7c15
+		z := state
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		table[i] = z ^ (z >> 31)
+	}
+	return table
+}()
+
+// FastCDCChunker cuts files where a rolling gear hash of the content
+// matches a mask (FastCDC). Cut points depend only on nearby bytes, so an
+// insertion or deletion changes the blocks around the edit and leaves the
+// rest of the file's blocks, and their hashes, untouched. Blocks are at
+// least MinSize and at most MaxSize bytes. Below AvgSize a harder mask is
+// used and above it an easier one, which keeps most blocks close to AvgSize.
+type FastCDCChunker struct {
+	MinSize int
+	AvgSize int
+	MaxSize int
+
+	maskS uint64
+	maskL uint64
+}
+
+func (cc *FastCDCChunker) Scheme() string {
+	return CHUNKING_FASTCDC
+}
+
+func (cc *FastCDCChunker) Describe(fileMetaData *FileMetaData) {
+	fileMetaData.ChunkingScheme = CHUNKING_FASTCDC
+	fileMetaData.BlockSize = int32(cc.AvgSize)
+	fileMetaData.MinChunkSize = int32(cc.MinSize)
+	fileMetaData.MaxChunkSize = int32(cc.MaxSize)
+}
+
+func (cc *FastCDCChunker) Chunk(r io.Reader, workers int) ([]HashedBlock, error) {
+	return hashChunks(r, workers, cc.MaxSize, cc.cut)
+}
+
+// cut returns the length of the block starting at data[0]
+func (cc *FastCDCChunker) cut(data []byte) int {
+	n := len(data)
+	if n <= cc.MinSize {
+		return n
+	}
+	normal := min(cc.AvgSize, n)
+	var fp uint64
+	i := cc.MinSize
+	for ; i < normal; i++ {
+		fp = (fp << 1) + gearTable[data[i]]
+		if fp&cc.maskS == 0 {
+			return i + 1
+		}
+	}
+	for ; i < n; i++ {
+		fp = (fp << 1) + gearTable[data[i]]
+		if fp&cc.maskL == 0 {
+			return i + 1
+		}
+	}
+	return n
+}
+
+// This line guarantees all method for FastCDCChunker are implemented
+var _ Chunker = new(FastCDCChunker)
+
+// highBits returns a mask of the n most significant bits. After shifting in
+// a byte per step, those bits depend on the last 64 bytes of content.
+func highBits(n int) uint64 {
+	return ^uint64(0) << (64 - n)
+}
+
+// NewFastCDCChunker creates a chunker for blocks of around avgSize bytes.
+// A zero minSize or maxSize defaults to a quarter or four times avgSize.
+func NewFastCDCChunker(minSize, avgSize, maxSize int) (*FastCDCChunker, error) {
+	if minSize == 0 {
+		minSize = avgSize / FASTCDC_SIZE_RATIO
+	}
+	if maxSize == 0 {
+		maxSize = avgSize * FASTCDC_SIZE_RATIO
+	}
+	if minSize <= 0 || avgSize < minSize || maxSize < avgSize {
+		return nil, fmt.Errorf("content-defined chunk sizes must satisfy 0 < min <= avg <= max, got %d, %d, %d", minSize, avgSize, maxSize)
+	}
+	// the number of mask bits that matches once every avgSize bytes
+	avgBits := max(bits.Len(uint(avgSize))-1, FASTCDC_NORMALIZATION+1)
+	return &FastCDCChunker{
+		MinSize: minSize,
+		AvgSize: avgSize,
+		MaxSize: maxSize,
+		maskS:   highBits(min(avgBits+FASTCDC_NORMALIZATION, 64)),
+		maskL:   highBits(avgBits - FASTCDC_NORMALIZATION),
+	}, nil
+}
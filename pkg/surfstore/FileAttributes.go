@@ -0,0 +1,82 @@
+package surfstore
+
+import (
+	"bytes"
+	"os"
+	"time"
+)
+
+// readAttributes records the permission bits, modification time and, if
+// withXattrs is set, the user extended attributes of a local file
+func readAttributes(path string, info os.FileInfo, withXattrs bool) (*FileMetaData, error) {
+	attrs := &FileMetaData{
+		Mode:  uint32(info.Mode().Perm()),
+		Mtime: info.ModTime().UnixNano(),
+	}
+	if withXattrs {
+		xattrs, err := getXattrs(path)
+		if err != nil {
+			return nil, err
+		}
+		attrs.Xattrs = xattrs
+	}
+	return attrs, nil
+}
+
+// setAttributes copies the attributes in attrs to a version about to be
+// pushed. A client that does not sync xattrs keeps those of prev.
+func setAttributes(fileMetaData *FileMetaData, attrs *FileMetaData, prev *FileMetaData, withXattrs bool) {
+	fileMetaData.Mode = attrs.GetMode()
+	fileMetaData.Mtime = attrs.GetMtime()
+	if withXattrs {
+		fileMetaData.Xattrs = attrs.GetXattrs()
+	} else {
+		fileMetaData.Xattrs = prev.GetXattrs()
+	}
+}
+
+// SameAttributes reports whether two versions of a file carry the same
+// permission bits and, if withXattrs is set, extended attributes. A zero
+// mode, recorded by clients that did not sync modes, matches any mode. The
+// modification time alone does not make a new version.
+func SameAttributes(a, b *FileMetaData, withXattrs bool) bool {
+	if a.GetMode() != 0 && b.GetMode() != 0 && a.GetMode() != b.GetMode() {
+		return false
+	}
+	if !withXattrs {
+		return true
+	}
+	if len(a.GetXattrs()) != len(b.GetXattrs()) {
+		return false
+	}
+	for name, value := range a.GetXattrs() {
+		other, ok := b.GetXattrs()[name]
+		if !ok || !bytes.Equal(value, other) {
+			return false
+		}
+	}
+	return true
+}
+
+// applyAttributes gives a local file the attributes of a version. The
+// modification time of directories is left alone, it changes whenever
+// their entries do.
+func applyAttributes(path string, fileMetaData *FileMetaData, withXattrs bool) error {
+	if mode := fileMetaData.GetMode(); mode != 0 {
+		if err := os.Chmod(path, os.FileMode(mode)); err != nil {
+			return err
+		}
+	}
+	if withXattrs {
+		if err := setXattrs(path, fileMetaData.GetXattrs()); err != nil {
+			return err
+		}
+	}
+	if mtime := fileMetaData.GetMtime(); mtime != 0 && !fileMetaData.GetDirectory() {
+		t := time.Unix(0, mtime)
+		if err := os.Chtimes(path, t, t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
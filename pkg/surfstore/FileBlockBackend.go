@@ -0,0 +1,148 @@
+package surfstore
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// FileBlockBackend keeps every block as a file under BaseDir, named by its
+// SHA-256 hash and fanned out by the first two hex characters of the hash
+// (BaseDir/ab/abcdef...). Blocks survive restarts. No locking is needed:
+// every write goes to its own temporary file and is renamed into place.
+type FileBlockBackend struct {
+	BaseDir string
+}
+
+func (fb *FileBlockBackend) Get(hash string) (*Block, error) {
+	path, err := fb.blockPath(hash)
+	if err != nil {
+		return nil, err
+	}
+	blockData, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, ErrBlockNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &Block{BlockData: blockData, BlockSize: int32(len(blockData))}, nil
+}
+
+func (fb *FileBlockBackend) Put(hash string, block *Block) error {
+	path, err := fb.blockPath(hash)
+	if err != nil {
+		return err
+	}
+	// blocks are content addressed, so an existing file already holds these bytes
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	return writeFileAtomic(path, block.GetBlockData())
+}
+
+func (fb *FileBlockBackend) Has(hash string) (bool, error) {
+	path, err := fb.blockPath(hash)
+	if err != nil {
+		return false, nil
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (fb *FileBlockBackend) Hashes() ([]string, error) {
+	hashes := []string{}
+	fanouts, err := os.ReadDir(fb.BaseDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, fanout := range fanouts {
+		if !fanout.IsDir() {
+			continue
+		}
+		entries, err := os.ReadDir(filepath.Join(fb.BaseDir, fanout.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if isBlockHash(entry.Name()) && entry.Name()[:2] == fanout.Name() {
+				hashes = append(hashes, entry.Name())
+			}
+		}
+	}
+	return hashes, nil
+}
+
+func (fb *FileBlockBackend) Close() error {
+	return nil
+}
+
+// blockPath maps a hash to its file, rejecting anything that is not a
+// hex-encoded SHA-256 so a request can never name a path outside BaseDir.
+func (fb *FileBlockBackend) blockPath(hash string) (string, error) {
+	if !isBlockHash(hash) {
+		return "", fmt.Errorf("Invalid block hash %q", hash)
+	}
+	return filepath.Join(fb.BaseDir, hash[:2], hash), nil
+}
+
+func isBlockHash(hash string) bool {
+	if len(hash) != hex.EncodedLen(32) {
+		return false
+	}
+	_, err := hex.DecodeString(hash)
+	return err == nil
+}
+
+// writeFileAtomic writes data to a temporary file next to path, syncs it and
+// renames it into place, so readers never observe a partially written file.
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// This line guarantees all method for FileBlockBackend are implemented
+var _ BlockBackend = new(FileBlockBackend)
+
+func NewFileBlockBackend(baseDir string) (*FileBlockBackend, error) {
+	if baseDir == "" {
+		return nil, fmt.Errorf("file block engine needs a block directory")
+	}
+	if err := os.MkdirAll(baseDir, 0755); err != nil {
+		return nil, err
+	}
+	return &FileBlockBackend{
+		BaseDir: baseDir,
+	}, nil
+}
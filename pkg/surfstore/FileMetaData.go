@@ -0,0 +1,88 @@
+package surfstore
+
+import (
+	"fmt"
+)
+
+var ErrInvalidFileMetaData = fmt.Errorf("invalid file metadata")
+
+// IsTombstone reports whether a version records a deletion, either with the
+// deleted field or with the legacy tombstone hash list
+func IsTombstone(fileMetaData *FileMetaData) bool {
+	if fileMetaData.GetDeleted() {
+		return true
+	}
+	hashList := fileMetaData.GetBlockHashList()
+	return len(hashList) > 0 && hashList[0] == TOMBSTONE_HASHVALUE
+}
+
+// IsEmptyFile reports whether a version is a regular file without content
+func IsEmptyFile(fileMetaData *FileMetaData) bool {
+	return !IsTombstone(fileMetaData) && !fileMetaData.GetDirectory() &&
+		fileMetaData.GetSymlinkTarget() == "" && len(FileBlockHashes(fileMetaData)) == 0
+}
+
+// FileBlockHashes returns the hashes of the blocks a version is made of,
+// without the legacy tombstone and empty file markers
+func FileBlockHashes(fileMetaData *FileMetaData) []string {
+	if IsTombstone(fileMetaData) {
+		return nil
+	}
+	return stripEmptyFileMarker(fileMetaData.GetBlockHashList())
+}
+
+func stripEmptyFileMarker(hashList []string) []string {
+	if len(hashList) > 0 && hashList[0] == EMPTYFILE_HASHVALUE {
+		return nil
+	}
+	return hashList
+}
+
+// NewTombstone creates the version that deletes the file prev describes.
+// The legacy marker is kept so clients that predate the deleted field see
+// the deletion too.
+func NewTombstone(prev *FileMetaData) *FileMetaData {
+	return &FileMetaData{
+		Filename:      prev.GetFilename(),
+		Version:       prev.GetVersion() + 1,
+		BlockHashList: []string{TOMBSTONE_HASHVALUE},
+		Directory:     prev.GetDirectory(),
+		Deleted:       true,
+	}
+}
+
+// UpgradeFileMetaData sets the deleted field of a version written by a
+// client that only marked deletions in its hash list
+func UpgradeFileMetaData(fileMetaData *FileMetaData) {
+	if IsTombstone(fileMetaData) {
+		fileMetaData.Deleted = true
+	}
+}
+
+// ValidateFileMetaData checks a version's name and that its fields agree.
+// The legacy markers are accepted only as the whole hash list.
+func ValidateFileMetaData(fileMetaData *FileMetaData) error {
+	if err := ValidateFilename(fileMetaData.GetFilename()); err != nil {
+		return err
+	}
+	invalid := func(reason string) error {
+		return fmt.Errorf("%w for %q: %s", ErrInvalidFileMetaData, fileMetaData.GetFilename(), reason)
+	}
+	hashList := fileMetaData.GetBlockHashList()
+	for i, hash := range hashList {
+		if (hash == TOMBSTONE_HASHVALUE || hash == EMPTYFILE_HASHVALUE) && (i > 0 || len(hashList) > 1) {
+			return invalid("marker hash mixed with block hashes")
+		}
+	}
+	switch {
+	case fileMetaData.GetSize() < 0:
+		return invalid("negative size")
+	case fileMetaData.GetDeleted() && len(hashList) > 0 && hashList[0] != TOMBSTONE_HASHVALUE:
+		return invalid("deleted version lists blocks")
+	case len(hashList) == 0 && !fileMetaData.GetDeleted() && fileMetaData.GetSize() > 0:
+		return invalid("non-empty file lists no blocks")
+	case (fileMetaData.GetDirectory() || fileMetaData.GetSymlinkTarget() != "") && len(FileBlockHashes(fileMetaData)) > 0:
+		return invalid("directory or symlink lists blocks")
+	}
+	return nil
+}
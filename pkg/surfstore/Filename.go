@@ -0,0 +1,53 @@
+package surfstore
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+var ErrInvalidFilename = fmt.Errorf("invalid filename")
+
+// ValidateFilename checks that a filename is a clean slash-separated path
+// relative to the sync root, so joining it with baseDir can never name a
+// path outside baseDir. Both the MetaStore and the client check names: the
+// MetaStore so no client can store a dangerous name, the client so a
+// malicious or buggy server cannot make it write outside baseDir.
+func ValidateFilename(fileName string) error {
+	invalid := func(reason string) error {
+		return fmt.Errorf("%w %q: %s", ErrInvalidFilename, fileName, reason)
+	}
+	switch {
+	case fileName == "":
+		return invalid("empty name")
+	case len(fileName) > MAX_FILENAME_LENGTH:
+		return invalid(fmt.Sprintf("longer than %d bytes", MAX_FILENAME_LENGTH))
+	case strings.ContainsRune(fileName, 0):
+		return invalid("contains a NUL byte")
+	case strings.ContainsRune(fileName, '\\'):
+		// a path separator on Windows clients
+		return invalid("contains a backslash")
+	case strings.HasPrefix(fileName, "/"):
+		return invalid("absolute path")
+	case len(fileName) >= 2 && fileName[1] == ':':
+		return invalid("absolute path")
+	case fileName == DEFAULT_META_FILENAME:
+		return invalid("reserved for the client's index")
+	}
+	for _, segment := range strings.Split(fileName, "/") {
+		switch {
+		case segment == "":
+			return invalid("empty path segment")
+		case segment == "." || segment == "..":
+			return invalid("relative path segment")
+		case len(segment) > MAX_FILENAME_SEGMENT_LENGTH:
+			return invalid(fmt.Sprintf("path segment longer than %d bytes", MAX_FILENAME_SEGMENT_LENGTH))
+		case strings.HasPrefix(segment, TEMPFILE_PREFIX):
+			return invalid("reserved for files being pulled")
+		}
+	}
+	if path.Clean(fileName) != fileName {
+		return invalid("not a clean path")
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package surfstore
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateFilename(t *testing.T) {
+	segment := strings.Repeat("a", MAX_FILENAME_SEGMENT_LENGTH)
+	long := strings.Repeat(segment+"/", MAX_FILENAME_LENGTH/len(segment)) + "a"
+	for _, tc := range []struct {
+		fileName string
+		valid    bool
+	}{
+		{"a.txt", true},
+		{"dir/sub/a.txt", true},
+		{".hidden", true},
+		{"a..b", true},
+		{"dir/index.db", true},
+		{segment, true},
+
+		// traversal
+		{"..", false},
+		{"../a", false},
+		{"dir/../../a", false},
+		{"dir/..", false},
+		{".", false},
+		{"./a", false},
+		{"dir/./a", false},
+		{"dir//a", false},
+		{"dir/", false},
+		{"..\\a", false},
+		{"dir\\a", false},
+
+		// absolute
+		{"/etc/passwd", false},
+		{"//server/share", false},
+		{"C:", false},
+		{"C:/Windows", false},
+		{"c:a", false},
+
+		// NUL byte
+		{"a\x00", false},
+		{"a\x00/../../etc/passwd", false},
+
+		// reserved
+		{"", false},
+		{DEFAULT_META_FILENAME, false},
+		{TEMPFILE_PREFIX + "a", false},
+		{"dir/" + TEMPFILE_PREFIX + "a", false},
+
+		// overlong
+		{segment + "a", false},
+		{"dir/" + segment + "a", false},
+		{long, false},
+	} {
+		err := ValidateFilename(tc.fileName)
+		if tc.valid && err != nil {
+			t.Errorf("ValidateFilename(%q): %v", tc.fileName, err)
+		}
+		if !tc.valid && !errors.Is(err, ErrInvalidFilename) {
+			t.Errorf("ValidateFilename(%q): got %v, want ErrInvalidFilename", tc.fileName, err)
+		}
+	}
+}
+
+// FuzzValidateFilename checks that every accepted name stays under baseDir
+// once joined to it
+func FuzzValidateFilename(f *testing.F) {
+	for _, seed := range []string{"a.txt", "dir/a", "../a", "/a", "a/../b", "C:a", "a\\..\\b", "a\x00", ".", DEFAULT_META_FILENAME} {
+		f.Add(seed)
+	}
+	baseDir := filepath.Join("base", "dir")
+	f.Fuzz(func(t *testing.T, fileName string) {
+		if ValidateFilename(fileName) != nil {
+			return
+		}
+		joined := ConcatPath(baseDir, fileName)
+		rel, err := filepath.Rel(baseDir, joined)
+		if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) || filepath.IsAbs(rel) {
+			t.Fatalf("accepted %q joins to %q, outside %q", fileName, joined, baseDir)
+		}
+		if joined == filepath.Join(baseDir, DEFAULT_META_FILENAME) {
+			t.Fatalf("accepted %q joins to the client's index", fileName)
+		}
+	})
+}
@@ -0,0 +1,80 @@
+package surfstore
+
+import (
+	"hash/fnv"
+	"sync"
+)
+
+const memoryBlockShards = 64
+
+// MemoryBlockBackend keeps blocks in Go maps. Nothing survives a restart.
+// Blocks are spread over shards with a lock each, so requests for
+// different blocks rarely wait on each other.
+type MemoryBlockBackend struct {
+	shards [memoryBlockShards]memoryBlockShard
+}
+
+type memoryBlockShard struct {
+	mu       sync.RWMutex
+	BlockMap map[string]*Block
+}
+
+func (mb *MemoryBlockBackend) Get(hash string) (*Block, error) {
+	shard := mb.shard(hash)
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+	if block, ok := shard.BlockMap[hash]; ok {
+		return block, nil
+	}
+	return nil, ErrBlockNotFound
+}
+
+func (mb *MemoryBlockBackend) Put(hash string, block *Block) error {
+	shard := mb.shard(hash)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	shard.BlockMap[hash] = block
+	return nil
+}
+
+func (mb *MemoryBlockBackend) Has(hash string) (bool, error) {
+	shard := mb.shard(hash)
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+	_, ok := shard.BlockMap[hash]
+	return ok, nil
+}
+
+func (mb *MemoryBlockBackend) Hashes() ([]string, error) {
+	hashes := []string{}
+	for i := range mb.shards {
+		shard := &mb.shards[i]
+		shard.mu.RLock()
+		for hash := range shard.BlockMap {
+			hashes = append(hashes, hash)
+		}
+		shard.mu.RUnlock()
+	}
+	return hashes, nil
+}
+
+func (mb *MemoryBlockBackend) Close() error {
+	return nil
+}
+
+func (mb *MemoryBlockBackend) shard(hash string) *memoryBlockShard {
+	h := fnv.New32a()
+	h.Write([]byte(hash))
+	return &mb.shards[h.Sum32()%memoryBlockShards]
+}
+
+// This line guarantees all method for MemoryBlockBackend are implemented
+var _ BlockBackend = new(MemoryBlockBackend)
+
+func NewMemoryBlockBackend() *MemoryBlockBackend {
+	mb := &MemoryBlockBackend{}
+	for i := range mb.shards {
+		mb.shards[i].BlockMap = map[string]*Block{}
+	}
+	return mb
+}
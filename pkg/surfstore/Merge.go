@@ -0,0 +1,317 @@
+package surfstore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"unicode/utf8"
+)
+
+// MergeText merges the changes local and remote made to base line by line.
+// Changes to different lines are combined. Where both sides changed the
+// same lines differently, the merged text holds both versions between
+// conflict markers and the number of such hunks is returned. ok is false if
+// the sides differ too much from base to be merged.
+func MergeText(base, local, remote []byte) (merged []byte, conflicts int, ok bool) {
+	baseLines, localLines, remoteLines := splitLines(base), splitLines(local), splitLines(remote)
+	localMatches, ok := matchLines(baseLines, localLines, MAX_MERGE_EDITS)
+	if !ok {
+		return nil, 0, false
+	}
+	remoteMatches, ok := matchLines(baseLines, remoteLines, MAX_MERGE_EDITS)
+	if !ok {
+		return nil, 0, false
+	}
+
+	var out bytes.Buffer
+	write := func(lines []string) {
+		for _, line := range lines {
+			out.WriteString(line)
+		}
+	}
+	marker := func(line string) {
+		if out.Len() > 0 && out.Bytes()[out.Len()-1] != '\n' {
+			out.WriteByte('\n')
+		}
+		out.WriteString(line + "\n")
+	}
+	// i, j and k walk base, local and remote, stopping at lines of base
+	// that both sides kept
+	i, j, k := 0, 0, 0
+	for i < len(baseLines) || j < len(localLines) || k < len(remoteLines) {
+		if i < len(baseLines) && localMatches[i] == j && remoteMatches[i] == k {
+			write(baseLines[i : i+1])
+			i, j, k = i+1, j+1, k+1
+			continue
+		}
+		nextI, nextJ, nextK := len(baseLines), len(localLines), len(remoteLines)
+		for n := i; n < len(baseLines); n++ {
+			if localMatches[n] >= 0 && remoteMatches[n] >= 0 {
+				nextI, nextJ, nextK = n, localMatches[n], remoteMatches[n]
+				break
+			}
+		}
+		baseHunk, localHunk, remoteHunk := baseLines[i:nextI], localLines[j:nextJ], remoteLines[k:nextK]
+		switch {
+		case slices.Equal(localHunk, baseHunk):
+			write(remoteHunk)
+		case slices.Equal(remoteHunk, baseHunk), slices.Equal(localHunk, remoteHunk):
+			write(localHunk)
+		default:
+			conflicts++
+			marker(MERGE_MARKER_LOCAL)
+			write(localHunk)
+			marker(MERGE_MARKER_SEPARATOR)
+			write(remoteHunk)
+			marker(MERGE_MARKER_REMOTE)
+		}
+		i, j, k = nextI, nextJ, nextK
+	}
+	return out.Bytes(), conflicts, true
+}
+
+// IsText reports whether data looks like text that can be merged line by
+// line: valid UTF-8 without NUL bytes
+func IsText(data []byte) bool {
+	return utf8.Valid(data) && bytes.IndexByte(data, 0) < 0
+}
+
+// splitLines splits text after every newline, so joining the lines gives
+// the text back
+func splitLines(data []byte) []string {
+	lines := []string{}
+	for len(data) > 0 {
+		n := bytes.IndexByte(data, '\n') + 1
+		if n == 0 {
+			n = len(data)
+		}
+		lines = append(lines, string(data[:n]))
+		data = data[n:]
+	}
+	return lines
+}
+
+// matchLines finds a shortest edit script from a to b with Myers' algorithm
+// and returns, for each line of a, the line of b it is kept as, or -1 if it
+// was removed. ok is false if more than maxEdits lines were added or removed.
+func matchLines(a, b []string, maxEdits int) ([]int, bool) {
+	matches := make([]int, len(a))
+	for i := range matches {
+		matches[i] = -1
+	}
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		matches[prefix] = prefix
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		matches[len(a)-1-suffix] = len(b) - 1 - suffix
+		suffix++
+	}
+	a, b = a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	n, m := len(a), len(b)
+
+	// v[offset+k] is the furthest x reached on diagonal k = x-y, trace keeps
+	// the diagonals -d..d after each step d to walk the path back
+	maxD := min(n+m, maxEdits)
+	offset := maxD + 1
+	v := make([]int, 2*maxD+3)
+	trace := [][]int{}
+	found := false
+	for d := 0; d <= maxD && !found; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x, y = x+1, y+1
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+		trace = append(trace, slices.Clone(v[offset-d:offset+d+1]))
+	}
+	if !found {
+		return nil, false
+	}
+
+	x, y := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		prev := func(k int) int { return trace[d-1][k+d-1] }
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && prev(k-1) < prev(k+1)) {
+			prevK = k + 1
+		}
+		prevX := prev(prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x, y = x-1, y-1
+			matches[prefix+x] = prefix + y
+		}
+		x, y = prevX, prevY
+	}
+	for x > 0 && y > 0 {
+		x, y = x-1, y-1
+		matches[prefix+x] = prefix + y
+	}
+	return matches, true
+}
+
+// mergeConflict tries to settle a file whose local version lost to remote
+// by merging both with base, the version they both started from. The
+// merged text is committed as the version after remote and only then
+// replaces the local file. Overlapping changes are committed between
+// conflict markers if opts.Merge is MERGE_MARKERS. nil is returned if the versions
+// cannot be merged, e.g. because they are not text, or if the merge must be
+// given up because yet another version won meanwhile.
+func mergeConflict(ctx context.Context, client ClientInterface, opts SyncOptions, base, lost, remote *FileMetaData, localBlocks LocalBlockIndex, stats *SyncStats) (*FileMetaData, error) {
+	if !mergeCandidate(base, lost, remote) {
+		return nil, nil
+	}
+	fileName := lost.GetFilename()
+	filePath := ConcatPath(opts.BaseDir, fileName)
+	localData, err := os.ReadFile(filePath)
+	if err != nil || int64(len(localData)) > MAX_MERGE_SIZE {
+		return nil, err
+	}
+	baseData, err := readVersion(ctx, client, opts.Workers, base, localBlocks, stats)
+	if err != nil {
+		return nil, err
+	}
+	remoteData, err := readVersion(ctx, client, opts.Workers, remote, localBlocks, stats)
+	if err != nil {
+		return nil, err
+	}
+	if !IsText(baseData) || !IsText(localData) || !IsText(remoteData) {
+		return nil, nil
+	}
+	merged, conflicts, ok := MergeText(baseData, localData, remoteData)
+	if !ok || (conflicts > 0 && opts.Merge != MERGE_MARKERS) {
+		return nil, nil
+	}
+
+	// the merged file is kept aside until its version is committed
+	file, err := os.CreateTemp(filepath.Dir(filePath), TEMPFILE_PREFIX+"*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+	if _, err := file.Write(merged); err != nil {
+		return nil, err
+	}
+	if err := file.Close(); err != nil {
+		return nil, err
+	}
+	if mode := lost.GetMode(); mode != 0 {
+		if err := os.Chmod(file.Name(), os.FileMode(mode)); err != nil {
+			return nil, err
+		}
+	}
+	info, err := os.Stat(file.Name())
+	if err != nil {
+		return nil, err
+	}
+	attrs, err := readAttributes(file.Name(), info, false)
+	if err != nil {
+		return nil, err
+	}
+	hashList, size, err := chunkLocalFile(file.Name(), opts.Chunker, localBlocks)
+	if err != nil {
+		return nil, err
+	}
+	mergedMetaData := &FileMetaData{
+		Filename:      fileName,
+		Version:       remote.GetVersion() + 1,
+		BlockHashList: hashList,
+		Size:          size,
+	}
+	opts.Chunker.Describe(mergedMetaData)
+	// the merged file keeps the local mode and extended attributes
+	setAttributes(mergedMetaData, attrs, lost, false)
+	blockStoreMap := make(map[string][]string)
+	if err := client.GetBlockStoreMap(hashList, &blockStoreMap); err != nil {
+		return nil, err
+	}
+	err = Push(ctx, client, opts, mergedMetaData, localBlocks, blockStoreMap, stats)
+	if errors.Is(err, ErrVersionConflict) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := applyAttributes(file.Name(), mergedMetaData, opts.Xattrs); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(file.Name(), filePath); err != nil {
+		return nil, err
+	}
+	localBlocks.Move(file.Name(), filePath)
+	stats.addMerge(fileName, conflicts)
+	return mergedMetaData, nil
+}
+
+// mergeCandidate reports whether three versions of a file may be merged:
+// all must be regular files, small enough to be held in memory. Whether
+// they are text is only known once they are read.
+func mergeCandidate(base, lost, remote *FileMetaData) bool {
+	for _, fileMetaData := range []*FileMetaData{base, lost, remote} {
+		if fileMetaData == nil || IsTombstone(fileMetaData) || fileMetaData.GetDirectory() ||
+			fileMetaData.GetSymlinkTarget() != "" || fileMetaData.GetSize() > MAX_MERGE_SIZE {
+			return false
+		}
+	}
+	return true
+}
+
+// readVersion reads the content of a version into memory. Blocks found in
+// local files are copied from disk, the rest is fetched from the BlockStores.
+func readVersion(ctx context.Context, client ClientInterface, workers int, fileMetaData *FileMetaData, localBlocks LocalBlockIndex, stats *SyncStats) ([]byte, error) {
+	hashList := FileBlockHashes(fileMetaData)
+	blockStoreMap := make(map[string][]string)
+	if err := client.GetBlockStoreMap(hashList, &blockStoreMap); err != nil {
+		return nil, err
+	}
+	reverseBlockStoreMap := make(map[string]string)
+	for addr, hashes := range blockStoreMap {
+		for _, hash := range hashes {
+			reverseBlockStoreMap[hash] = addr
+		}
+	}
+	blocks := make([][]byte, len(hashList))
+	err := transferBlocks(ctx, workers, len(hashList), func(i int) error {
+		hash := hashList[i]
+		if blockData, ok := localBlocks.Read(hash); ok {
+			blocks[i] = blockData
+			return nil
+		}
+		block := Block{}
+		if err := client.GetBlock(hash, reverseBlockStoreMap[hash], &block); err != nil {
+			return err
+		}
+		blocks[i] = block.GetBlockData()
+		stats.count(&stats.BlocksDownloaded, &stats.BytesDownloaded, 1, int64(len(blocks[i])))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	data := bytes.Join(blocks, nil)
+	if size := fileMetaData.GetSize(); size > 0 && int64(len(data)) != size {
+		return nil, fmt.Errorf("read %d bytes of version %d of %q, it records %d",
+			len(data), fileMetaData.GetVersion(), fileMetaData.GetFilename(), size)
+	}
+	return data, nil
+}
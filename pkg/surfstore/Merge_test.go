@@ -0,0 +1,121 @@
+package surfstore
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMergeText(t *testing.T) {
+	conflict := func(local, remote string) string {
+		return MERGE_MARKER_LOCAL + "\n" + local + MERGE_MARKER_SEPARATOR + "\n" + remote + MERGE_MARKER_REMOTE + "\n"
+	}
+	for _, tc := range []struct {
+		name                string
+		base, local, remote string
+		want                string
+		conflicts           int
+	}{
+		{"no changes", "a\nb\n", "a\nb\n", "a\nb\n", "a\nb\n", 0},
+		{"local change only", "a\nb\n", "A\nb\n", "a\nb\n", "A\nb\n", 0},
+		{"remote change only", "a\nb\n", "a\nb\n", "a\nB\n", "a\nB\n", 0},
+		{"separate changes", "a\nb\nc\nd\ne\n", "a\nB\nc\nd\ne\n", "a\nb\nc\nD\ne\n", "a\nB\nc\nD\ne\n", 0},
+		{"same change on both sides", "a\nb\nc\n", "a\nX\nc\n", "a\nX\nc\n", "a\nX\nc\n", 0},
+		{"overlapping changes", "a\nb\nc\nd\ne\n", "a\nb\nL\nd\ne\n", "a\nb\nR\nd\ne\n", "a\nb\n" + conflict("L\n", "R\n") + "d\ne\n", 1},
+		{"two overlapping hunks", "a\nb\nc\nd\ne\n", "L1\nb\nc\nd\nL2\n", "R1\nb\nc\nd\nR2\n", conflict("L1\n", "R1\n") + "b\nc\nd\n" + conflict("L2\n", "R2\n"), 2},
+		{"adjacent changes overlap", "a\nb\nc\n", "a\nB\nc\n", "a\nb\nC\n", "a\n" + conflict("B\nc\n", "b\nC\n"), 1},
+		{"inserts at the same line", "a\nb\n", "a\nx\nb\n", "a\ny\nb\n", "a\n" + conflict("x\n", "y\n") + "b\n", 1},
+		{"same insert on both sides", "a\nb\n", "a\nx\nb\n", "a\nx\nb\n", "a\nx\nb\n", 0},
+		{"inserts at different lines", "a\nb\nc\n", "x\na\nb\nc\n", "a\nb\nc\ny\n", "x\na\nb\nc\ny\n", 0},
+		{"delete against modify", "a\nb\nc\n", "a\nc\n", "a\nB\nc\n", "a\n" + conflict("", "B\n") + "c\n", 1},
+		{"delete against a change elsewhere", "a\nb\nc\nd\n", "a\nc\nd\n", "a\nb\nc\nD\n", "a\nc\nD\n", 0},
+		{"delete on both sides", "a\nb\nc\n", "a\nc\n", "a\nc\n", "a\nc\n", 0},
+		{"empty base, one side adds", "", "x\n", "", "x\n", 0},
+		{"empty base, both sides add", "", "x\n", "y\n", conflict("x\n", "y\n"), 1},
+		{"empty base, same text added", "", "x\ny\n", "x\ny\n", "x\ny\n", 0},
+		{"everything deleted on one side", "a\nb\n", "", "a\nb\n", "", 0},
+		{"no trailing newline", "a\nb\nc", "A\nb\nc", "a\nb\nC", "A\nb\nC", 0},
+		{"trailing newline added", "a\nb\nc", "a\nb\nc\n", "A\nb\nc", "A\nb\nc\n", 0},
+		{"conflict without trailing newline", "a", "b", "c", conflict("b\n", "c\n"), 1},
+	} {
+		merged, conflicts, ok := MergeText([]byte(tc.base), []byte(tc.local), []byte(tc.remote))
+		if !ok {
+			t.Errorf("%s: MergeText gave up", tc.name)
+			continue
+		}
+		if string(merged) != tc.want || conflicts != tc.conflicts {
+			t.Errorf("%s: MergeText = %q with %d conflicts, want %q with %d", tc.name, merged, conflicts, tc.want, tc.conflicts)
+		}
+	}
+
+	lines := strings.Repeat("line\n", MAX_MERGE_EDITS+1)
+	if _, _, ok := MergeText(nil, []byte(lines), nil); ok {
+		t.Fatalf("MergeText merged more than %d edits", MAX_MERGE_EDITS)
+	}
+}
+
+func TestSyncMergesTextChangedOnBothSides(t *testing.T) {
+	const base = "one\ntwo\nthree\nfour\nfive\n"
+	for _, tc := range []struct {
+		name          string
+		merge         string
+		local, remote string
+		// merged text, empty if the resolver keeps both versions
+		want      string
+		conflicts int
+	}{
+		{"separate changes", MERGE_CLEAN, "one\nTWO\nthree\nfour\nfive\n", "one\ntwo\nthree\nFOUR\nfive\n", "one\nTWO\nthree\nFOUR\nfive\n", 0},
+		{"overlapping changes with markers", MERGE_MARKERS, "one\ntwo\nmine\nfour\nfive\n", "one\ntwo\ntheirs\nfour\nfive\n",
+			"one\ntwo\n" + MERGE_MARKER_LOCAL + "\nmine\n" + MERGE_MARKER_SEPARATOR + "\ntheirs\n" + MERGE_MARKER_REMOTE + "\nfour\nfive\n", 1},
+		{"overlapping changes", MERGE_CLEAN, "one\ntwo\nmine\nfour\nfive\n", "one\ntwo\ntheirs\nfour\nfive\n", "", 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			client := newFakeClient()
+			dir, otherDir := t.TempDir(), t.TempDir()
+			writeTestFile(t, dir, "notes.txt", base)
+			syncDir(t, client, dir)
+			syncDir(t, client, otherDir)
+			writeTestFile(t, otherDir, "notes.txt", tc.remote)
+			syncDir(t, client, otherDir)
+
+			writeTestFile(t, dir, "notes.txt", tc.local)
+			opts := DefaultSyncOptions(dir, 4)
+			opts.Merge = tc.merge
+			report := syncWith(t, client, opts)
+
+			conflicts := report.Stats.Conflicts
+			if tc.want == "" {
+				// the merge is given up, both versions are kept
+				if len(conflicts) != 1 || conflicts[0].Merged || conflicts[0].Resolution != KeepBoth {
+					t.Fatalf("Sync reported conflicts %v, want both versions kept", conflicts)
+				}
+				if got := readTestFile(t, dir, "notes.txt"); got != tc.remote {
+					t.Fatalf("notes.txt holds %q, want the winning version", got)
+				}
+				return
+			}
+			if len(conflicts) != 1 || !conflicts[0].Merged || conflicts[0].Overlaps != tc.conflicts {
+				t.Fatalf("Sync reported conflicts %v, want one merge with %d overlaps", conflicts, tc.conflicts)
+			}
+			want := []FileAction{{FileName: "notes.txt", Action: ActionConflict, Version: 3}}
+			if !sameFileActions(report.Files, want) {
+				t.Fatalf("Sync reported %v, want %v", report.Files, want)
+			}
+			if got := readTestFile(t, dir, "notes.txt"); got != tc.want {
+				t.Fatalf("notes.txt holds %q, want %q", got, tc.want)
+			}
+
+			// the merged version reaches the other client
+			syncDir(t, client, otherDir)
+			if got := readTestFile(t, otherDir, "notes.txt"); got != tc.want {
+				t.Fatalf("the other client holds %q, want the merged text", got)
+			}
+			var fileInfoMap map[string]*FileMetaData
+			if err := client.GetFileInfoMap(&fileInfoMap); err != nil {
+				t.Fatal(err)
+			}
+			if len(fileInfoMap) != 1 {
+				t.Fatalf("the server lists %d files, want no conflict copy", len(fileInfoMap))
+			}
+		})
+	}
+}
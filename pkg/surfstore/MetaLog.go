@@ -0,0 +1,242 @@
+package surfstore
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// MetaLog makes a MetaStore durable. Every accepted update is appended to a
+// write-ahead log and fsynced before UpdateFile returns, and every
+// SnapshotInterval updates the history of every file is written to a
+// snapshot and the log is started afresh.
+//
+// A log record is a 4-byte big-endian payload length, a 4-byte CRC32 of the
+// payload and the payload itself, a marshalled FileMetaData. Replaying a
+// record adds its FileMetaData to the history of its file unless the
+// history already holds that version, so replaying records that are already
+// part of the snapshot is harmless.
+type MetaLog struct {
+	Dir              string
+	SnapshotInterval int
+
+	wal *os.File
+	// size of the intact records in wal, where the next one is written
+	size                 int64
+	entriesSinceSnapshot int
+	// set once a failed append could not be undone, every later append
+	// fails with it
+	failed error
+}
+
+const walHeaderSize = 8
+
+// records claiming to be larger than this can only come from corruption
+const maxWALRecordSize = 1 << 30
+
+// OpenMetaLog loads the latest snapshot in dir, replays the log written after
+// it and returns the recovered history of every file. A record cut short by
+// a crash is dropped, since the update it describes was never acknowledged.
+func OpenMetaLog(dir string, snapshotInterval int) (*MetaLog, map[string][]*FileMetaData, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, nil, err
+	}
+	if snapshotInterval <= 0 {
+		snapshotInterval = DEFAULT_SNAPSHOT_INTERVAL
+	}
+	l := &MetaLog{
+		Dir:              dir,
+		SnapshotInterval: snapshotInterval,
+	}
+
+	fileHistories, err := l.loadSnapshot()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	wal, err := os.OpenFile(filepath.Join(dir, META_WAL_FILENAME), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, nil, err
+	}
+	validSize, replayed, err := replayWAL(wal, fileHistories)
+	if err != nil {
+		wal.Close()
+		return nil, nil, err
+	}
+	if err := wal.Truncate(validSize); err != nil {
+		wal.Close()
+		return nil, nil, err
+	}
+	l.wal = wal
+	l.size = validSize
+	l.entriesSinceSnapshot = replayed
+	return l, fileHistories, nil
+}
+
+// Append durably records an accepted update. A failed append is cut off
+// the log again, so a torn record can never hide the updates appended
+// after it.
+func (l *MetaLog) Append(fileMetaData *FileMetaData) error {
+	if l.failed != nil {
+		return l.failed
+	}
+	record, err := encodeRecord(fileMetaData)
+	if err != nil {
+		return err
+	}
+	_, err = l.wal.WriteAt(record, l.size)
+	if err == nil {
+		err = l.wal.Sync()
+	}
+	if err != nil {
+		if truncErr := l.wal.Truncate(l.size); truncErr != nil {
+			// the log cannot be trusted any more
+			l.failed = fmt.Errorf("meta log failed: %v", truncErr)
+		}
+		return err
+	}
+	l.size += int64(len(record))
+	l.entriesSinceSnapshot++
+	return nil
+}
+
+// SnapshotDue reports whether enough updates were logged to take a snapshot
+func (l *MetaLog) SnapshotDue() bool {
+	return l.entriesSinceSnapshot >= l.SnapshotInterval
+}
+
+// Snapshot writes fileHistories, which must include every appended update,
+// and empties the log
+func (l *MetaLog) Snapshot(fileHistories map[string][]*FileMetaData) error {
+	data, err := proto.Marshal(newMetaSnapshot(fileHistories))
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(filepath.Join(l.Dir, META_SNAPSHOT_FILENAME), data); err != nil {
+		return err
+	}
+	if err := l.wal.Truncate(0); err != nil {
+		return err
+	}
+	if err := l.wal.Sync(); err != nil {
+		return err
+	}
+	l.size = 0
+	l.entriesSinceSnapshot = 0
+	return nil
+}
+
+func (l *MetaLog) Close() error {
+	return l.wal.Close()
+}
+
+// loadSnapshot reads the snapshot's histories
+func (l *MetaLog) loadSnapshot() (map[string][]*FileMetaData, error) {
+	data, err := os.ReadFile(filepath.Join(l.Dir, META_SNAPSHOT_FILENAME))
+	if os.IsNotExist(err) {
+		return make(map[string][]*FileMetaData), nil
+	} else if err != nil {
+		return nil, err
+	}
+	snapshot := &MetaSnapshot{}
+	if err := proto.Unmarshal(data, snapshot); err != nil {
+		return nil, fmt.Errorf("Error reading meta snapshot: %v", err)
+	}
+	return metaSnapshotHistories(snapshot), nil
+}
+
+func newMetaSnapshot(fileHistories map[string][]*FileMetaData) *MetaSnapshot {
+	snapshot := &MetaSnapshot{
+		FileHistories: make(map[string]*FileHistory, len(fileHistories)),
+	}
+	for fileName, versions := range fileHistories {
+		snapshot.FileHistories[fileName] = &FileHistory{Versions: versions}
+	}
+	return snapshot
+}
+
+// metaSnapshotHistories returns the history of every file in a snapshot
+func metaSnapshotHistories(snapshot *MetaSnapshot) map[string][]*FileMetaData {
+	fileHistories := make(map[string][]*FileMetaData)
+	for fileName, history := range snapshot.GetFileHistories() {
+		if versions := history.GetVersions(); len(versions) > 0 {
+			fileHistories[fileName] = versions
+		}
+	}
+	return fileHistories
+}
+
+// replayWAL adds every intact record to fileHistories and returns the size
+// of the log prefix holding them
+func replayWAL(wal *os.File, fileHistories map[string][]*FileMetaData) (int64, int, error) {
+	if _, err := wal.Seek(0, io.SeekStart); err != nil {
+		return 0, 0, err
+	}
+	reader := bufio.NewReader(wal)
+	var validSize int64
+	replayed := 0
+	for {
+		fileMetaData := &FileMetaData{}
+		size, err := readRecord(reader, fileMetaData)
+		if err != nil {
+			return 0, 0, err
+		}
+		if size == 0 {
+			return validSize, replayed, nil
+		}
+		addVersion(fileHistories, fileMetaData)
+		validSize += size
+		replayed++
+	}
+}
+
+// encodeRecord frames a message as a log record
+func encodeRecord(message proto.Message) ([]byte, error) {
+	payload, err := proto.Marshal(message)
+	if err != nil {
+		return nil, err
+	}
+	record := make([]byte, walHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(record[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(record[4:8], crc32.ChecksumIEEE(payload))
+	copy(record[walHeaderSize:], payload)
+	return record, nil
+}
+
+// readRecord reads the next log record into message and returns its size.
+// Size 0 means the log ends here: at its end, or at a record that is cut
+// short or corrupt.
+func readRecord(reader *bufio.Reader, message proto.Message) (int64, error) {
+	header := make([]byte, walHeaderSize)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	payloadSize := binary.BigEndian.Uint32(header[0:4])
+	if payloadSize > maxWALRecordSize {
+		return 0, nil
+	}
+	payload := make([]byte, payloadSize)
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:8]) {
+		return 0, nil
+	}
+	if err := proto.Unmarshal(payload, message); err != nil {
+		return 0, nil
+	}
+	return int64(walHeaderSize + len(payload)), nil
+}
@@ -0,0 +1,138 @@
+package surfstore
+
+import (
+	context "context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func openDurableMetaStore(t *testing.T, dir string, snapshotInterval int) *MetaStore {
+	t.Helper()
+	m, err := NewDurableMetaStore(nil, dir, snapshotInterval)
+	if err != nil {
+		t.Fatalf("NewDurableMetaStore: %v", err)
+	}
+	t.Cleanup(func() { m.Log.Close() })
+	return m
+}
+
+// commitMetaVersions commits versions from to to of fileName, tagged by
+// version
+func commitMetaVersions(t *testing.T, m *MetaStore, fileName string, from, to int32) {
+	t.Helper()
+	for version := from; version <= to; version++ {
+		v, err := m.UpdateFile(context.Background(), testVersion(fileName, version, int64(version)))
+		if err != nil || v.GetVersion() != version {
+			t.Fatalf("UpdateFile %q version %d: got %d, %v", fileName, version, v.GetVersion(), err)
+		}
+	}
+}
+
+func checkMetaHistory(t *testing.T, m *MetaStore, fileName string, versions int32) {
+	t.Helper()
+	history := m.FileHistories[fileName]
+	if len(history) != int(versions) {
+		t.Fatalf("%q has %d versions, want %d", fileName, len(history), versions)
+	}
+	for i, fileMetaData := range history {
+		if fileMetaData.GetVersion() != int32(i+1) || fileMetaData.GetMtime() != int64(i+1) {
+			t.Fatalf("%q version %d is %v", fileName, i+1, fileMetaData)
+		}
+	}
+	if latest := m.FileMetaMap[fileName]; latest.GetVersion() != versions {
+		t.Fatalf("%q is at version %d, want %d", fileName, latest.GetVersion(), versions)
+	}
+}
+
+func TestMetaLogReplaysAfterSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	m := openDurableMetaStore(t, dir, 5)
+	// versions 1 to 10 end up in snapshots, 11 to 13 only in the log
+	commitMetaVersions(t, m, "a", 1, 13)
+	commitMetaVersions(t, m, "b", 1, 1)
+	m.Log.Close()
+	if _, err := os.Stat(filepath.Join(dir, META_SNAPSHOT_FILENAME)); err != nil {
+		t.Fatalf("no snapshot was taken: %v", err)
+	}
+
+	m = openDurableMetaStore(t, dir, 5)
+	checkMetaHistory(t, m, "a", 13)
+	checkMetaHistory(t, m, "b", 1)
+	// the recovered store goes on from where it was
+	commitMetaVersions(t, m, "a", 14, 14)
+	m.Log.Close()
+	checkMetaHistory(t, openDurableMetaStore(t, dir, 5), "a", 14)
+}
+
+func TestMetaLogDropsTornTail(t *testing.T) {
+	dir := t.TempDir()
+	m := openDurableMetaStore(t, dir, 100)
+	commitMetaVersions(t, m, "a", 1, 3)
+	m.Log.Close()
+
+	// a crash in the middle of appending version 4
+	walPath := filepath.Join(dir, META_WAL_FILENAME)
+	record, err := encodeRecord(testVersion("a", 4, 4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wal, err := os.OpenFile(walPath, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wal.Write(record[:len(record)-1])
+	wal.Close()
+
+	m = openDurableMetaStore(t, dir, 100)
+	checkMetaHistory(t, m, "a", 3)
+	// the torn record is gone, so later appends are replayed
+	commitMetaVersions(t, m, "a", 4, 5)
+	m.Log.Close()
+	checkMetaHistory(t, openDurableMetaStore(t, dir, 100), "a", 5)
+}
+
+func TestMetaLogStopsAtCRCMismatch(t *testing.T) {
+	dir := t.TempDir()
+	m := openDurableMetaStore(t, dir, 100)
+	commitMetaVersions(t, m, "a", 1, 3)
+	m.Log.Close()
+
+	// corrupt the payload of version 3
+	walPath := filepath.Join(dir, META_WAL_FILENAME)
+	data, err := os.ReadFile(walPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[len(data)-1] ^= 0xff
+	if err := os.WriteFile(walPath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m = openDurableMetaStore(t, dir, 100)
+	checkMetaHistory(t, m, "a", 2)
+	info, err := os.Stat(walPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != m.Log.size {
+		t.Fatalf("the log holds %d bytes, but only %d are intact", info.Size(), m.Log.size)
+	}
+}
+
+func TestMetaLogRejectsAppendsAfterFailure(t *testing.T) {
+	m := openDurableMetaStore(t, t.TempDir(), 100)
+	commitMetaVersions(t, m, "a", 1, 1)
+	// neither the append nor undoing it can succeed
+	m.Log.wal.Close()
+	if err := m.Log.Append(testVersion("a", 2, 2)); err == nil {
+		t.Fatal("Append to a closed log succeeded")
+	}
+	if m.Log.failed == nil {
+		t.Fatal("a failed append that could not be undone did not fail the log")
+	}
+	if v, err := m.UpdateFile(context.Background(), testVersion("a", 2, 2)); err == nil {
+		t.Fatalf("UpdateFile on a failed log committed version %d", v.GetVersion())
+	}
+	checkMetaHistory(t, m, "a", 1)
+}
@@ -2,44 +2,217 @@ package surfstore
 
 import (
 	context "context"
+	"log"
+	"sync"
+	"time"
 
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 	emptypb "google.golang.org/protobuf/types/known/emptypb"
 )
 
 type MetaStore struct {
+	// mu guards FileMetaMap and FileHistories, UpdateFile holds it across
+	// the whole compare-and-set so racing clients cannot both win the same
+	// version
+	mu          sync.RWMutex
 	FileMetaMap map[string]*FileMetaData
+	// every version of every file, oldest first. The last one is the
+	// version in FileMetaMap.
+	FileHistories map[string][]*FileMetaData
 	// BlockStoreAddr string
 	BlockStoreAddrs    []string
 	ConsistentHashRing *ConsistentHashRing
+	// Log persists accepted updates, nil keeps the MetaStore in memory only
+	Log *MetaLog
+	// connections used to check that updated files' blocks exist
+	blockStoreConns *ConnPool
 	UnimplementedMetaStoreServer
 }
 
 func (m *MetaStore) GetFileInfoMap(ctx context.Context, _ *emptypb.Empty) (*FileInfoMap, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	// hand out a copy, the map keeps changing after this returns
+	fileInfoMap := make(map[string]*FileMetaData, len(m.FileMetaMap))
+	for fileName, fileMetaData := range m.FileMetaMap {
+		fileInfoMap[fileName] = fileMetaData
+	}
 	return &FileInfoMap{
-		FileInfoMap: m.FileMetaMap,
+		FileInfoMap: fileInfoMap,
 	}, nil
 }
 
+// UpdateFile only accepts a new version once every block it references is
+// held by its responsible BlockStore. Otherwise it answers version -1 and
+// lists the missing hashes, so clients must upload blocks before committing.
 func (m *MetaStore) UpdateFile(ctx context.Context, fileMetaData *FileMetaData) (*Version, error) {
+	if err := ValidateFileMetaData(fileMetaData); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	UpgradeFileMetaData(fileMetaData)
+	fileMetaData.CommitTime = time.Now().UnixNano()
+	missingBlockHashes, err := m.MissingBlocks(ctx, fileMetaData)
+	if err != nil {
+		return nil, err
+	}
+	if len(missingBlockHashes) > 0 {
+		return &Version{Version: -1, MissingBlockHashes: missingBlockHashes}, nil
+	}
+	return m.ApplyUpdate(fileMetaData)
+}
+
+// ApplyUpdate performs the version compare-and-set of UpdateFile without
+// checking blocks. A replicated MetaStore checks blocks before it logs an
+// update and applies it with this once the update is committed.
+func (m *MetaStore) ApplyUpdate(fileMetaData *FileMetaData) (*Version, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	fileName := fileMetaData.GetFilename()
 	if prevMetaData, ok := m.FileMetaMap[fileName]; ok {
 		prevVersion := prevMetaData.GetVersion()
 		currVersion := fileMetaData.GetVersion()
 		if prevVersion+1 == currVersion {
-			m.FileMetaMap[fileName] = fileMetaData
+			if err := m.commit(fileMetaData); err != nil {
+				return nil, err
+			}
 			return &Version{Version: currVersion}, nil
 		} else {
 			return &Version{Version: -1}, nil
 		}
 	} else {
-		m.FileMetaMap[fileName] = fileMetaData
+		// a file the server has never seen must start at version 1
 		if fileMetaData.GetVersion() != int32(1) {
 			return &Version{Version: -1}, nil
 		}
+		if err := m.commit(fileMetaData); err != nil {
+			return nil, err
+		}
 		return &Version{Version: fileMetaData.GetVersion()}, nil
 	}
 }
 
+// MissingBlocks asks the responsible BlockStores which blocks of a file they
+// do not hold
+func (m *MetaStore) MissingBlocks(ctx context.Context, fileMetaData *FileMetaData) ([]string, error) {
+	hashesByAddr := make(map[string][]string)
+	for _, hash := range FileBlockHashes(fileMetaData) {
+		if len(m.BlockStoreAddrs) == 0 {
+			return nil, status.Error(codes.FailedPrecondition, "No BlockStore is configured")
+		}
+		addr := m.ConsistentHashRing.GetResponsibleServer(hash)
+		hashesByAddr[addr] = append(hashesByAddr[addr], hash)
+	}
+	missingBlockHashes := []string{}
+	for addr, hashes := range hashesByAddr {
+		conn, err := m.blockStoreConns.Get(addr)
+		if err != nil {
+			return nil, err
+		}
+		missing, err := NewBlockStoreClient(conn).MissingBlocks(ctx, &BlockHashes{Hashes: hashes})
+		if err != nil {
+			return nil, status.Errorf(codes.Unavailable, "Error checking blocks on %s: %v", addr, err)
+		}
+		missingBlockHashes = append(missingBlockHashes, missing.GetHashes()...)
+	}
+	return missingBlockHashes, nil
+}
+
+// commit logs an accepted update before applying it, so a version that was
+// returned to a client is never lost. The caller holds m.mu.
+func (m *MetaStore) commit(fileMetaData *FileMetaData) error {
+	if m.Log != nil {
+		if err := m.Log.Append(fileMetaData); err != nil {
+			return err
+		}
+	}
+	m.FileMetaMap[fileMetaData.GetFilename()] = fileMetaData
+	addVersion(m.FileHistories, fileMetaData)
+	if m.Log != nil && m.Log.SnapshotDue() {
+		if err := m.Log.Snapshot(m.FileHistories); err != nil {
+			log.Printf("Error taking meta snapshot: %v", err)
+		}
+	}
+	return nil
+}
+
+// addVersion appends a version to the history of its file. Versions the
+// history already holds, e.g. log records replayed after a snapshot, are
+// skipped.
+func addVersion(fileHistories map[string][]*FileMetaData, fileMetaData *FileMetaData) {
+	fileName := fileMetaData.GetFilename()
+	versions := fileHistories[fileName]
+	if len(versions) > 0 && versions[len(versions)-1].GetVersion() >= fileMetaData.GetVersion() {
+		return
+	}
+	fileHistories[fileName] = append(versions, fileMetaData)
+}
+
+// Snapshot copies the history of every file
+func (m *MetaStore) Snapshot() *MetaSnapshot {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	fileHistories := make(map[string][]*FileMetaData, len(m.FileHistories))
+	for fileName, versions := range m.FileHistories {
+		fileHistories[fileName] = append([]*FileMetaData{}, versions...)
+	}
+	return newMetaSnapshot(fileHistories)
+}
+
+// Restore replaces every file with the given histories
+func (m *MetaStore) Restore(fileHistories map[string][]*FileMetaData) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.FileMetaMap = make(map[string]*FileMetaData, len(fileHistories))
+	for fileName, versions := range fileHistories {
+		m.FileMetaMap[fileName] = versions[len(versions)-1]
+	}
+	m.FileHistories = fileHistories
+}
+
+func (m *MetaStore) GetFileHistory(ctx context.Context, fileName *FileName) (*FileHistory, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	versions, ok := m.FileHistories[fileName.GetFilename()]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "No such file %q", fileName.GetFilename())
+	}
+	// the history keeps growing after this returns
+	return &FileHistory{Versions: append([]*FileMetaData{}, versions...)}, nil
+}
+
+// RestoreFileVersion makes an earlier version of a file current again by
+// committing a copy of it as the next version. Like UpdateFile it answers
+// version -1 and the missing hashes if BlockStores lost the old blocks.
+func (m *MetaStore) RestoreFileVersion(ctx context.Context, fileVersion *FileVersion) (*Version, error) {
+	restored, err := m.restoredVersion(fileVersion)
+	if err != nil {
+		return nil, err
+	}
+	return m.UpdateFile(ctx, restored)
+}
+
+// restoredVersion copies a version of a file from its history, numbered as
+// the version that follows the latest one
+func (m *MetaStore) restoredVersion(fileVersion *FileVersion) (*FileMetaData, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	fileName := fileVersion.GetFilename()
+	versions, ok := m.FileHistories[fileName]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "No such file %q", fileName)
+	}
+	for _, fileMetaData := range versions {
+		if fileMetaData.GetVersion() == fileVersion.GetVersion() {
+			restored := proto.Clone(fileMetaData).(*FileMetaData)
+			restored.Version = versions[len(versions)-1].GetVersion() + 1
+			return restored, nil
+		}
+	}
+	return nil, status.Errorf(codes.NotFound, "No version %d of %q", fileVersion.GetVersion(), fileName)
+}
+
 /*
 func (m *MetaStore) GetBlockStoreAddr(ctx context.Context, _ *emptypb.Empty) (*BlockStoreAddr, error) {
 	return &BlockStoreAddr{Addr: m.BlockStoreAddr}, nil
@@ -80,7 +253,25 @@ func NewMetaStore(blockStoreAddr string) *MetaStore {
 func NewMetaStore(blockStoreAddrs []string) *MetaStore {
 	return &MetaStore{
 		FileMetaMap:        map[string]*FileMetaData{},
+		FileHistories:      map[string][]*FileMetaData{},
 		BlockStoreAddrs:    blockStoreAddrs,
 		ConsistentHashRing: NewConsistentHashRing(blockStoreAddrs),
+		blockStoreConns:    NewConnPool(DEFAULT_CONN_POOL_SIZE),
+	}
+}
+
+// NewDurableMetaStore recovers a MetaStore from the snapshot and write-ahead
+// log in metaDir and keeps logging every accepted update there
+func NewDurableMetaStore(blockStoreAddrs []string, metaDir string, snapshotInterval int) (*MetaStore, error) {
+	metaLog, fileHistories, err := OpenMetaLog(metaDir, snapshotInterval)
+	if err != nil {
+		return nil, err
+	}
+	m := NewMetaStore(blockStoreAddrs)
+	for fileName, versions := range fileHistories {
+		m.FileMetaMap[fileName] = versions[len(versions)-1]
 	}
+	m.FileHistories = fileHistories
+	m.Log = metaLog
+	return m, nil
 }
@@ -0,0 +1,70 @@
+package surfstore
+
+import (
+	context "context"
+	"sync"
+	"testing"
+)
+
+// TestUpdateFileHasOneWinnerPerVersion races clients for every version of
+// one file. Exactly one of them may win each version, and the history must
+// hold the winners in order.
+func TestUpdateFileHasOneWinnerPerVersion(t *testing.T) {
+	const clients = 16
+	const versions = 50
+	for name, open := range map[string]func(t *testing.T) *MetaStore{
+		"memory": func(t *testing.T) *MetaStore {
+			return NewMetaStore(nil)
+		},
+		"durable": func(t *testing.T) *MetaStore {
+			m, err := NewDurableMetaStore(nil, t.TempDir(), 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return m
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			m := open(t)
+			for version := int32(1); version <= versions; version++ {
+				var wg sync.WaitGroup
+				var mu sync.Mutex
+				winners := []int64{}
+				start := make(chan struct{})
+				for c := 0; c < clients; c++ {
+					wg.Add(1)
+					go func(c int64) {
+						defer wg.Done()
+						<-start
+						v, err := m.UpdateFile(context.Background(), testVersion("a", version, c))
+						if err != nil {
+							t.Errorf("UpdateFile version %d: %v", version, err)
+							return
+						}
+						if v.GetVersion() == version {
+							mu.Lock()
+							winners = append(winners, c)
+							mu.Unlock()
+						} else if v.GetVersion() != -1 {
+							t.Errorf("UpdateFile version %d: got version %d", version, v.GetVersion())
+						}
+					}(int64(c))
+				}
+				close(start)
+				wg.Wait()
+				if len(winners) != 1 {
+					t.Fatalf("version %d was won by clients %v, want exactly one", version, winners)
+				}
+
+				history, err := m.GetFileHistory(context.Background(), &FileName{Filename: "a"})
+				if err != nil {
+					t.Fatal(err)
+				}
+				last := history.GetVersions()[len(history.GetVersions())-1]
+				if len(history.GetVersions()) != int(version) || last.GetVersion() != version || last.GetMtime() != winners[0] {
+					t.Fatalf("after version %d the history ends with %v", version, last)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package surfstore
+
+import (
+	"context"
+	"sort"
+)
+
+// PlanEntry is what a sync would do with a file
+type PlanEntry struct {
+	FileName string `json:"fileName"`
+	Action   Action `json:"action"`
+	// bytes of file content that would be uploaded and downloaded, before
+	// deduplication and reuse of local blocks
+	UploadBytes   int64 `json:"uploadBytes,omitempty"`
+	DownloadBytes int64 `json:"downloadBytes,omitempty"`
+	// for a conflict, what the conflict resolver would decide, and whether
+	// a merge of text would be tried first
+	Resolution string `json:"resolution,omitempty"`
+	Merge      bool   `json:"merge,omitempty"`
+	// why the file would be skipped
+	Reason string `json:"reason,omitempty"`
+}
+
+// SyncPlan is what a sync would do, file by file in name order, with totals
+type SyncPlan struct {
+	Files           []PlanEntry `json:"files"`
+	Uploads         int         `json:"uploads"`
+	UploadBytes     int64       `json:"uploadBytes"`
+	Downloads       int         `json:"downloads"`
+	DownloadBytes   int64       `json:"downloadBytes"`
+	RemoteDeletions int         `json:"remoteDeletions"`
+	LocalDeletions  int         `json:"localDeletions"`
+	Conflicts       int         `json:"conflicts"`
+	Skipped         int         `json:"skipped"`
+}
+
+func (plan *SyncPlan) add(entry PlanEntry) {
+	plan.Files = append(plan.Files, entry)
+	plan.UploadBytes += entry.UploadBytes
+	plan.DownloadBytes += entry.DownloadBytes
+	switch entry.Action {
+	case ActionUpload, ActionUploadAttributes:
+		plan.Uploads++
+	case ActionDownload, ActionDownloadAttributes:
+		plan.Downloads++
+	case ActionDeleteRemote:
+		plan.RemoteDeletions++
+	case ActionDeleteLocal:
+		plan.LocalDeletions++
+	case ActionConflict:
+		plan.Conflicts++
+	case ActionSkip:
+		plan.Skipped++
+	}
+}
+
+// Plan scans the base directory and compares it with index.db and the
+// server's file list the way Sync does, and returns what Sync would do.
+// Nothing is uploaded, downloaded or committed and index.db is not
+// written. Versions other clients commit meanwhile make the sync differ
+// from its plan. Errors are returned as a *SyncError.
+func Plan(ctx context.Context, client ClientInterface, opts SyncOptions) (*SyncPlan, error) {
+	opts = opts.withDefaults()
+	client = withContext(ctx, client)
+	plan := &SyncPlan{Files: []PlanEntry{}}
+	skips := make(map[string][]PlanEntry)
+	skip := func(fileName string, err error) {
+		skips[fileName] = append(skips[fileName], PlanEntry{FileName: fileName, Action: ActionSkip, Reason: err.Error()})
+	}
+	fail := func(op string, err error) (*SyncPlan, error) {
+		return plan, &SyncError{Op: op, Err: err}
+	}
+
+	scan, err := scanLocal(ctx, opts, skip)
+	if err != nil {
+		return fail(SYNC_OP_SCAN, err)
+	}
+	localMap, err := loadMetaFileCopy(opts.BaseDir)
+	if err != nil {
+		return fail(SYNC_OP_INDEX, err)
+	}
+	localIndexMap := validIndexEntries(localMap)
+	remoteIndexMap := make(map[string]*FileMetaData)
+	if err := client.GetFileInfoMap(&remoteIndexMap); err != nil {
+		return fail(SYNC_OP_LIST, err)
+	}
+	dropInvalidRemoteEntries(opts, remoteIndexMap, skip)
+
+	fileNames := make(map[string]bool)
+	for fileName := range skips {
+		fileNames[fileName] = true
+	}
+	for _, fileMaps := range []map[string]*FileMetaData{localIndexMap, remoteIndexMap} {
+		for fileName := range fileMaps {
+			fileNames[fileName] = true
+		}
+	}
+	for fileName := range scan.metaMap {
+		fileNames[fileName] = true
+	}
+	sorted := make([]string, 0, len(fileNames))
+	for fileName := range fileNames {
+		sorted = append(sorted, fileName)
+	}
+	sort.Strings(sorted)
+
+	for _, fileName := range sorted {
+		if err := ctx.Err(); err != nil {
+			return fail(SYNC_OP_SCAN, err)
+		}
+		for _, entry := range skips[fileName] {
+			plan.add(entry)
+		}
+		entry, err := planFile(opts, scan, fileName, localIndexMap[fileName], remoteIndexMap[fileName])
+		if err != nil {
+			return plan, &SyncError{Op: SYNC_OP_SCAN, FileName: fileName, Err: err}
+		}
+		if entry != nil {
+			plan.add(*entry)
+		}
+	}
+	return plan, nil
+}
+
+// planFile reports what a sync would do with a file given its version in
+// index.db and on the server, either may be nil. nil means nothing.
+func planFile(opts SyncOptions, scan *localScan, fileName string, base *FileMetaData, remote *FileMetaData) (*PlanEntry, error) {
+	decision, err := decideFile(opts, scan, fileName, base, remote)
+	if err != nil || decision == nil {
+		return nil, err
+	}
+	entry := &PlanEntry{FileName: fileName, Action: decision.action}
+	switch decision.action {
+	case ActionUpload:
+		entry.UploadBytes = scan.sizes[fileName]
+	case ActionDownload:
+		entry.DownloadBytes = remote.GetSize()
+	case ActionConflict:
+		// how the local version that loses to remote is settled
+		lost := decision.version
+		resolution := opts.ConflictResolver.Resolve(fileName, base, lost, remote)
+		entry.Resolution = resolution.String()
+		entry.Merge = opts.Merge != MERGE_OFF && mergeCandidate(base, lost, remote)
+		if resolution == KeepLocal || (resolution == KeepBoth && !IsTombstone(lost)) {
+			entry.UploadBytes = lost.GetSize()
+		}
+		if resolution != KeepLocal {
+			entry.DownloadBytes = remote.GetSize()
+		}
+	}
+	return entry, nil
+}
@@ -0,0 +1,132 @@
+package surfstore
+
+import (
+	context "context"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// planMatchesSync plans a sync of baseDir, runs it, and checks that it did
+// what the plan said, file by file
+func planMatchesSync(t *testing.T, client ClientInterface, baseDir string) *SyncReport {
+	t.Helper()
+	opts := DefaultSyncOptions(baseDir, 4)
+	plan, err := Plan(context.Background(), client, opts)
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	report := syncWith(t, client, opts)
+
+	planned := []string{}
+	for _, entry := range plan.Files {
+		planned = append(planned, entry.Action.String()+" "+entry.FileName)
+	}
+	// a conflict also reports how it was settled, by pulling the winner
+	// or uploading a conflict copy
+	settled := make(map[string]bool)
+	for _, conflict := range report.Stats.Conflicts {
+		settled[conflict.FileName] = true
+		settled[conflict.ConflictCopy] = true
+	}
+	done := []string{}
+	for _, fileAction := range report.Files {
+		if settled[fileAction.FileName] && fileAction.Action != ActionConflict {
+			continue
+		}
+		done = append(done, fileAction.Action.String()+" "+fileAction.FileName)
+	}
+	slices.Sort(planned)
+	slices.Sort(done)
+	if !slices.Equal(planned, done) {
+		t.Fatalf("planned %v, but the sync did %v", planned, done)
+	}
+	return report
+}
+
+func TestPlanMatchesSync(t *testing.T) {
+	client := newFakeClient()
+	dirA, dirB := t.TempDir(), t.TempDir()
+	writeTestFile(t, dirA, "changed.txt", "old\n")
+	writeTestFile(t, dirA, "deleted.txt", "deleted\n")
+	writeTestFile(t, dirA, "chmod.txt", "chmod\n")
+	writeTestFile(t, dirA, "dir/nested.txt", "nested\n")
+	planMatchesSync(t, client, dirA)
+	planMatchesSync(t, client, dirB)
+
+	writeTestFile(t, dirA, "changed.txt", "new\n")
+	writeTestFile(t, dirA, "new.txt", "new\n")
+	if err := os.Remove(ConcatPath(dirA, "deleted.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(ConcatPath(dirA, "chmod.txt"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	planMatchesSync(t, client, dirA)
+	planMatchesSync(t, client, dirB)
+	planMatchesSync(t, client, dirB)
+}
+
+// TestPlanMatchesSyncForFilesTheServerLost syncs an index.db that lists
+// files the server does not have, e.g. after the server was reset. Files
+// still present are uploaded as new files, at version 1, and files deleted
+// locally are forgotten.
+func TestPlanMatchesSyncForFilesTheServerLost(t *testing.T) {
+	baseDir := t.TempDir()
+	writeTestFile(t, baseDir, "kept.txt", "kept\n")
+	writeTestFile(t, baseDir, "changed.txt", "old\n")
+	writeTestFile(t, baseDir, "deleted.txt", "deleted\n")
+	writeTestFile(t, baseDir, "chmod.txt", "chmod\n")
+	oldServer := newFakeClient()
+	syncDir(t, oldServer, baseDir)
+	syncDir(t, oldServer, baseDir)
+
+	writeTestFile(t, baseDir, "changed.txt", "new\n")
+	if err := os.Remove(ConcatPath(baseDir, "deleted.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(ConcatPath(baseDir, "chmod.txt"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	client := newFakeClient()
+	report := planMatchesSync(t, client, baseDir)
+	want := []FileAction{
+		{FileName: "kept.txt", Action: ActionUpload, Version: 1},
+		{FileName: "changed.txt", Action: ActionUpload, Version: 1},
+		{FileName: "chmod.txt", Action: ActionUpload, Version: 1},
+	}
+	if !sameFileActions(report.Files, want) {
+		t.Fatalf("Sync reported %v, want %v", report.Files, want)
+	}
+
+	otherDir := t.TempDir()
+	syncDir(t, client, otherDir)
+	for _, fileName := range []string{"kept.txt", "changed.txt", "chmod.txt"} {
+		if got, want := readTestFile(t, otherDir, fileName), readTestFile(t, baseDir, fileName); got != want {
+			t.Fatalf("%s holds %q on the other client, want %q", fileName, got, want)
+		}
+	}
+	if _, err := os.Stat(ConcatPath(otherDir, "deleted.txt")); !os.IsNotExist(err) {
+		t.Fatalf("the deleted file came back: %v", err)
+	}
+	if report := planMatchesSync(t, client, baseDir); len(report.Files) != 0 {
+		t.Fatalf("a second sync reported %v", report.Files)
+	}
+}
+
+func TestPlanMatchesSyncForConflicts(t *testing.T) {
+	client := newFakeClient()
+	dirA, dirB := t.TempDir(), t.TempDir()
+	writeTestFile(t, dirA, "a.txt", "base\n")
+	syncDir(t, client, dirA)
+	syncDir(t, client, dirB)
+
+	writeTestFile(t, dirB, "a.txt", "theirs\n")
+	syncDir(t, client, dirB)
+	writeTestFile(t, dirA, "a.txt", strings.Repeat("mine\n", 2))
+	report := planMatchesSync(t, client, dirA)
+	if len(report.Stats.Conflicts) != 1 {
+		t.Fatalf("Sync reported conflicts %v", report.Stats.Conflicts)
+	}
+}
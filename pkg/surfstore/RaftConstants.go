@@ -0,0 +1,36 @@
+package surfstore
+
+import (
+	"fmt"
+	"time"
+)
+
+var ErrServerCrashed = fmt.Errorf("Server is crashed.")
+var ErrNotLeader = fmt.Errorf("Server is not the leader")
+var ErrCommitUnknown = fmt.Errorf("Server lost its leadership before the update was known to be committed")
+
+// ErrorInfo reason and metadata key a follower uses to point clients at the leader
+const RAFT_NOT_LEADER_REASON string = "NOT_LEADER"
+const RAFT_LEADER_ADDR_KEY string = "leader"
+
+const RAFT_NO_VOTE int64 = -1
+
+const RAFT_HEARTBEAT_INTERVAL time.Duration = 100 * time.Millisecond
+const RAFT_ELECTION_TIMEOUT_MIN time.Duration = 400 * time.Millisecond
+const RAFT_ELECTION_TIMEOUT_MAX time.Duration = 800 * time.Millisecond
+const RAFT_RPC_TIMEOUT time.Duration = 200 * time.Millisecond
+const RAFT_TICK_INTERVAL time.Duration = 20 * time.Millisecond
+const RAFT_MAX_APPEND_ENTRIES int64 = 1000
+
+const RAFT_STATE_FILENAME string = "raft.state"
+const RAFT_LOG_FILENAME string = "raft.log"
+const RAFT_SNAPSHOT_FILENAME string = "raft.snapshot"
+
+// applied entries between snapshots that compact the log
+const DEFAULT_RAFT_SNAPSHOT_INTERVAL int = 1000
+
+// largest piece of a snapshot sent in one InstallSnapshot call
+const RAFT_SNAPSHOT_CHUNK_SIZE int = 1 << 20
+
+// number of passes a client makes over all MetaStore replicas before giving up
+const META_FAILOVER_ROUNDS int = 3
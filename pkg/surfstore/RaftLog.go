@@ -0,0 +1,233 @@
+package surfstore
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// RaftLog keeps a replica's Raft state on stable storage. The term and vote
+// are rewritten in RAFT_STATE_FILENAME whenever they change, log entries are
+// appended to RAFT_LOG_FILENAME and fsynced, and a snapshot of the MetaStore
+// in RAFT_SNAPSHOT_FILENAME replaces the entries it covers, so no write
+// grows with the age of the cluster.
+//
+// The log file starts with the 8-byte big-endian index of the entry before
+// its first record, followed by one record per entry in the format MetaLog
+// uses, each holding a marshalled UpdateOperation.
+type RaftLog struct {
+	Dir string
+
+	file *os.File
+	// index of the entry before the first record, and where each record starts
+	base    int64
+	offsets []int64
+	size    int64
+}
+
+const raftLogHeaderSize = 8
+
+// OpenRaftLog recovers the term, vote and snapshot stored in dir, and the
+// log entries that follow the snapshot. A record cut short by a crash is
+// dropped, like the rest of an unacknowledged append.
+func OpenRaftLog(dir string) (*RaftLog, *RaftState, []*UpdateOperation, *RaftSnapshot, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, nil, nil, nil, err
+	}
+	l := &RaftLog{Dir: dir}
+	state := &RaftState{}
+	if found, err := readMessage(filepath.Join(dir, RAFT_STATE_FILENAME), state); err != nil {
+		return nil, nil, nil, nil, err
+	} else if !found {
+		state.VotedFor = RAFT_NO_VOTE
+	}
+	snapshot := &RaftSnapshot{}
+	if _, err := readMessage(filepath.Join(dir, RAFT_SNAPSHOT_FILENAME), snapshot); err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	var entries []*UpdateOperation
+	if _, err := os.Stat(filepath.Join(dir, RAFT_LOG_FILENAME)); os.IsNotExist(err) {
+		if err := l.rewrite(0, nil); err != nil {
+			return nil, nil, nil, nil, err
+		}
+	} else if err != nil {
+		return nil, nil, nil, nil, err
+	} else if entries, err = l.load(); err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	// a crash while compacting leaves entries the snapshot already covers
+	snapshotIndex := snapshot.GetLastIncludedIndex()
+	if l.base > snapshotIndex {
+		l.Close()
+		return nil, nil, nil, nil, fmt.Errorf("raft log starts after entry %d, but the snapshot ends at entry %d", l.base, snapshotIndex)
+	}
+	if covered := snapshotIndex - l.base; covered > 0 {
+		if covered > int64(len(entries)) || entries[covered-1].GetTerm() != snapshot.GetLastIncludedTerm() {
+			entries = nil
+		} else {
+			entries = entries[covered:]
+		}
+		if err := l.rewrite(snapshotIndex, entries); err != nil {
+			return nil, nil, nil, nil, err
+		}
+	}
+	if entries == nil {
+		entries = []*UpdateOperation{}
+	}
+	return l, state, entries, snapshot, nil
+}
+
+// SaveState durably records the current term and vote
+func (l *RaftLog) SaveState(term int64, votedFor int64) error {
+	data, err := proto.Marshal(&RaftState{Term: term, VotedFor: votedFor})
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(l.Dir, RAFT_STATE_FILENAME), data)
+}
+
+// Append durably stores entries as the log from index firstIndex on,
+// replacing any entries the log held from there
+func (l *RaftLog) Append(firstIndex int64, entries []*UpdateOperation) error {
+	if firstIndex <= l.base || firstIndex > l.base+int64(len(l.offsets))+1 {
+		return fmt.Errorf("cannot append entry %d to a raft log holding entries %d to %d", firstIndex, l.base+1, l.base+int64(len(l.offsets)))
+	}
+	if kept := firstIndex - l.base - 1; kept < int64(len(l.offsets)) {
+		if err := l.file.Truncate(l.offsets[kept]); err != nil {
+			return err
+		}
+		l.size = l.offsets[kept]
+		l.offsets = l.offsets[:kept]
+	}
+	var buf bytes.Buffer
+	offsets := make([]int64, 0, len(entries))
+	for _, entry := range entries {
+		record, err := encodeRecord(entry)
+		if err != nil {
+			return err
+		}
+		offsets = append(offsets, l.size+int64(buf.Len()))
+		buf.Write(record)
+	}
+	if _, err := l.file.WriteAt(buf.Bytes(), l.size); err != nil {
+		return err
+	}
+	if err := l.file.Sync(); err != nil {
+		return err
+	}
+	l.offsets = append(l.offsets, offsets...)
+	l.size += int64(buf.Len())
+	return nil
+}
+
+// Compact durably replaces the log with snapshot and the entries that
+// follow it
+func (l *RaftLog) Compact(snapshot *RaftSnapshot, entries []*UpdateOperation) error {
+	data, err := proto.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(filepath.Join(l.Dir, RAFT_SNAPSHOT_FILENAME), data); err != nil {
+		return err
+	}
+	return l.rewrite(snapshot.GetLastIncludedIndex(), entries)
+}
+
+func (l *RaftLog) Close() error {
+	if l.file == nil {
+		return nil
+	}
+	return l.file.Close()
+}
+
+// rewrite replaces the log file with one holding entries from index base+1
+func (l *RaftLog) rewrite(base int64, entries []*UpdateOperation) error {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, base)
+	offsets := make([]int64, 0, len(entries))
+	for _, entry := range entries {
+		record, err := encodeRecord(entry)
+		if err != nil {
+			return err
+		}
+		offsets = append(offsets, int64(buf.Len()))
+		buf.Write(record)
+	}
+	path := filepath.Join(l.Dir, RAFT_LOG_FILENAME)
+	if err := writeFileAtomic(path, buf.Bytes()); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	l.Close()
+	l.file = file
+	l.base = base
+	l.offsets = offsets
+	l.size = int64(buf.Len())
+	return nil
+}
+
+// load reads the log file and drops a torn record at its end
+func (l *RaftLog) load() ([]*UpdateOperation, error) {
+	file, err := os.OpenFile(filepath.Join(l.Dir, RAFT_LOG_FILENAME), os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	reader := bufio.NewReader(file)
+	if err := binary.Read(reader, binary.BigEndian, &l.base); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("Error reading raft log: %v", err)
+	}
+	entries := []*UpdateOperation{}
+	l.offsets = []int64{}
+	l.size = raftLogHeaderSize
+	for {
+		entry := &UpdateOperation{}
+		size, err := readRecord(reader, entry)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		if size == 0 {
+			break
+		}
+		entries = append(entries, entry)
+		l.offsets = append(l.offsets, l.size)
+		l.size += size
+	}
+	if err := file.Truncate(l.size); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if _, err := file.Seek(l.size, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+	l.file = file
+	return entries, nil
+}
+
+// readMessage unmarshals the file at path into message and reports whether
+// the file exists
+func readMessage(path string, message proto.Message) (bool, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	if err := proto.Unmarshal(data, message); err != nil {
+		return true, fmt.Errorf("Error reading %s: %v", filepath.Base(path), err)
+	}
+	return true, nil
+}
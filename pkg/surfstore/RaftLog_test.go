@@ -0,0 +1,143 @@
+package surfstore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+)
+
+func raftEntries(terms ...int64) []*UpdateOperation {
+	entries := []*UpdateOperation{}
+	for i, term := range terms {
+		entries = append(entries, &UpdateOperation{Term: term, FileMetaData: testVersion("a", int32(i+1), term)})
+	}
+	return entries
+}
+
+func openTestRaftLog(t *testing.T, dir string) (*RaftLog, *RaftState, []*UpdateOperation, *RaftSnapshot) {
+	t.Helper()
+	l, state, entries, snapshot, err := OpenRaftLog(dir)
+	if err != nil {
+		t.Fatalf("OpenRaftLog: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+	return l, state, entries, snapshot
+}
+
+func checkRaftEntries(t *testing.T, got, want []*UpdateOperation) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d entries, want %d", len(got), len(want))
+	}
+	for i := range got {
+		if !proto.Equal(got[i], want[i]) {
+			t.Fatalf("entry %d is %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestRaftLogRecoversStateAndEntries(t *testing.T) {
+	dir := t.TempDir()
+	l, state, entries, snapshot := openTestRaftLog(t, dir)
+	if state.GetTerm() != 0 || state.GetVotedFor() != RAFT_NO_VOTE || len(entries) != 0 || snapshot.GetLastIncludedIndex() != 0 {
+		t.Fatalf("a new raft log holds state %v and snapshot %v", state, snapshot)
+	}
+	// a vote for replica 0 must not read back as no vote
+	if err := l.SaveState(3, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Append(1, raftEntries(1, 1, 2)); err != nil {
+		t.Fatal(err)
+	}
+	// a new leader overwrites the last entry and appends more
+	if err := l.Append(3, raftEntries(1, 1, 3, 3)[2:]); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Append(6, raftEntries(1)); err == nil {
+		t.Fatal("Append accepted an entry after a gap")
+	}
+	l.Close()
+
+	_, state, entries, _ = openTestRaftLog(t, dir)
+	if state.GetTerm() != 3 || state.GetVotedFor() != 0 {
+		t.Fatalf("recovered term %d and vote %d, want 3 and 0", state.GetTerm(), state.GetVotedFor())
+	}
+	checkRaftEntries(t, entries, raftEntries(1, 1, 3, 3))
+}
+
+func TestRaftLogDropsTornRecord(t *testing.T) {
+	dir := t.TempDir()
+	l, _, _, _ := openTestRaftLog(t, dir)
+	if err := l.Append(1, raftEntries(1, 1)); err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+	file, err := os.OpenFile(filepath.Join(dir, RAFT_LOG_FILENAME), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Write([]byte{0, 0, 0, 42, 1, 2})
+	file.Close()
+
+	l, _, entries, _ := openTestRaftLog(t, dir)
+	checkRaftEntries(t, entries, raftEntries(1, 1))
+	// appends continue where the intact records end
+	if err := l.Append(3, raftEntries(1, 1, 2)[2:]); err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+	_, _, entries, _ = openTestRaftLog(t, dir)
+	checkRaftEntries(t, entries, raftEntries(1, 1, 2))
+}
+
+func TestRaftLogCompacts(t *testing.T) {
+	dir := t.TempDir()
+	l, _, _, _ := openTestRaftLog(t, dir)
+	entries := raftEntries(1, 1, 1, 2, 2)
+	if err := l.Append(1, entries); err != nil {
+		t.Fatal(err)
+	}
+	state := newMetaSnapshot(map[string][]*FileMetaData{"a": {testVersion("a", 1, 1)}})
+	if err := l.Compact(&RaftSnapshot{LastIncludedIndex: 3, LastIncludedTerm: 1, State: state}, entries[3:]); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Append(6, raftEntries(1, 1, 1, 2, 2, 2)[5:]); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Append(3, entries[2:3]); err == nil {
+		t.Fatal("Append accepted an entry the snapshot covers")
+	}
+	l.Close()
+
+	_, _, recovered, snapshot := openTestRaftLog(t, dir)
+	if snapshot.GetLastIncludedIndex() != 3 || snapshot.GetLastIncludedTerm() != 1 || !proto.Equal(snapshot.GetState(), state) {
+		t.Fatalf("recovered snapshot %v", snapshot)
+	}
+	checkRaftEntries(t, recovered, raftEntries(1, 1, 1, 2, 2, 2)[3:])
+}
+
+// a crash between writing a snapshot and rewriting the log leaves a log
+// that still holds the entries the snapshot covers
+func TestRaftLogSkipsEntriesCoveredBySnapshot(t *testing.T) {
+	dir := t.TempDir()
+	l, _, _, _ := openTestRaftLog(t, dir)
+	if err := l.Append(1, raftEntries(1, 1, 2, 2)); err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+	data, err := proto.Marshal(&RaftSnapshot{LastIncludedIndex: 2, LastIncludedTerm: 1, State: &MetaSnapshot{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, RAFT_SNAPSHOT_FILENAME), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	l, _, entries, _ := openTestRaftLog(t, dir)
+	checkRaftEntries(t, entries, raftEntries(1, 1, 2, 2)[2:])
+	if err := l.Append(5, raftEntries(1, 1, 2, 2, 3)[4:]); err != nil {
+		t.Fatal(err)
+	}
+}
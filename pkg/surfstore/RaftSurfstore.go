@@ -0,0 +1,928 @@
+package surfstore
+
+import (
+	context "context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	grpc "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
+)
+
+type raftRole int
+
+const (
+	raftFollower raftRole = iota
+	raftCandidate
+	raftLeader
+)
+
+// RaftSurfstore is a MetaStore replicated with Raft among a fixed set of
+// peers. Every UpdateFile is appended to the leader's log and only applied to
+// the underlying MetaStore once a majority of peers stored it, so versions
+// handed out to clients survive the loss of any minority of replicas.
+// Followers refuse client requests and point at the leader instead.
+type RaftSurfstore struct {
+	id    int64
+	peers []string
+
+	// the replicated state machine
+	metaStore *MetaStore
+
+	mu       sync.Mutex
+	role     raftRole
+	term     int64
+	votedFor int64
+	// the snapshot replaces every entry up to snapshotIndex, and log[i]
+	// holds log index snapshotIndex+i+1
+	log           []*UpdateOperation
+	snapshotIndex int64
+	snapshotTerm  int64
+	snapshot      []byte // marshalled MetaSnapshot, nil before the first snapshot
+	commitIndex   int64
+	lastApplied   int64
+	leaderId      int64
+	// a snapshot is taken once this many entries were applied after the last one
+	snapshotInterval int64
+	// a snapshot being received from the leader
+	pendingSnapshot *InstallSnapshotInput
+
+	// leader state, indexed by peer id
+	nextIndex   []int64
+	matchIndex  []int64
+	replicating []bool
+
+	electionDeadline time.Time
+	lastHeartbeat    time.Time
+	waiters          map[int64]*raftWaiter
+
+	// fault injection for the in-process test harness
+	isCrashed   bool
+	unreachable map[int64]bool
+
+	// persists term, vote, log and snapshots, nil keeps them in memory
+	raftLog *RaftLog
+
+	peerConns *ConnPool
+	stop      chan struct{}
+
+	UnimplementedRaftSurfstoreServer
+	UnimplementedMetaStoreServer
+}
+
+// raftWaiter is a client UpdateFile waiting for its log entry to be applied
+type raftWaiter struct {
+	term   int64
+	result chan *raftResult
+}
+
+type raftResult struct {
+	version *Version
+	err     error
+}
+
+func (s *RaftSurfstore) GetFileInfoMap(ctx context.Context, empty *emptypb.Empty) (*FileInfoMap, error) {
+	if err := s.confirmLeadership(ctx); err != nil {
+		return nil, err
+	}
+	return s.metaStore.GetFileInfoMap(ctx, empty)
+}
+
+func (s *RaftSurfstore) UpdateFile(ctx context.Context, fileMetaData *FileMetaData) (*Version, error) {
+	if s.crashed() {
+		return nil, crashedError()
+	}
+	if isLeader, _ := s.IsLeader(); !isLeader {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return nil, s.notLeaderErrorLocked()
+	}
+	if err := ValidateFileMetaData(fileMetaData); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	UpgradeFileMetaData(fileMetaData)
+	// stamped before it enters the log, so every replica records the same time
+	fileMetaData.CommitTime = time.Now().UnixNano()
+	// blocks are checked before the update enters the log, so applying a
+	// committed entry never depends on the BlockStores
+	missingBlockHashes, err := s.metaStore.MissingBlocks(ctx, fileMetaData)
+	if err != nil {
+		return nil, err
+	}
+	if len(missingBlockHashes) > 0 {
+		return &Version{Version: -1, MissingBlockHashes: missingBlockHashes}, nil
+	}
+
+	s.mu.Lock()
+	if s.isCrashed {
+		s.mu.Unlock()
+		return nil, crashedError()
+	}
+	if s.role != raftLeader {
+		err := s.notLeaderErrorLocked()
+		s.mu.Unlock()
+		return nil, err
+	}
+	index := s.appendLocked(&UpdateOperation{Term: s.term, FileMetaData: fileMetaData})
+	waiter := &raftWaiter{term: s.term, result: make(chan *raftResult, 1)}
+	s.waiters[index] = waiter
+	s.broadcastLocked()
+	s.advanceCommitLocked()
+	s.mu.Unlock()
+
+	select {
+	case result := <-waiter.result:
+		return result.version, result.err
+	case <-ctx.Done():
+		s.mu.Lock()
+		delete(s.waiters, index)
+		s.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+func (s *RaftSurfstore) GetFileHistory(ctx context.Context, fileName *FileName) (*FileHistory, error) {
+	if err := s.confirmLeadership(ctx); err != nil {
+		return nil, err
+	}
+	return s.metaStore.GetFileHistory(ctx, fileName)
+}
+
+// RestoreFileVersion commits the restored copy like any other update. If
+// the leader's applied state is behind, the copy's version number loses the
+// compare-and-set and version -1 is answered.
+func (s *RaftSurfstore) RestoreFileVersion(ctx context.Context, fileVersion *FileVersion) (*Version, error) {
+	if s.crashed() {
+		return nil, crashedError()
+	}
+	if isLeader, _ := s.IsLeader(); !isLeader {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return nil, s.notLeaderErrorLocked()
+	}
+	restored, err := s.metaStore.restoredVersion(fileVersion)
+	if err != nil {
+		return nil, err
+	}
+	return s.UpdateFile(ctx, restored)
+}
+
+func (s *RaftSurfstore) GetBlockStoreMap(ctx context.Context, hashes *BlockHashes) (*BlockStoreMap, error) {
+	if s.crashed() {
+		return nil, crashedError()
+	}
+	return s.metaStore.GetBlockStoreMap(ctx, hashes)
+}
+
+func (s *RaftSurfstore) GetBlockStoreAddrs(ctx context.Context, empty *emptypb.Empty) (*BlockStoreAddrs, error) {
+	if s.crashed() {
+		return nil, crashedError()
+	}
+	return s.metaStore.GetBlockStoreAddrs(ctx, empty)
+}
+
+// AppendEntries replicates the leader's log onto this server. On failure
+// MatchedIndex is the last index the leader should try as PrevLogIndex next.
+func (s *RaftSurfstore) AppendEntries(ctx context.Context, input *AppendEntryInput) (*AppendEntryOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.isCrashed {
+		return nil, crashedError()
+	}
+	output := &AppendEntryOutput{ServerId: s.id, Term: s.term}
+	if input.GetTerm() < s.term {
+		return output, nil
+	}
+	if input.GetTerm() > s.term || s.role != raftFollower {
+		s.becomeFollowerLocked(input.GetTerm())
+		output.Term = s.term
+	}
+	s.leaderId = input.GetLeaderId()
+	s.resetElectionDeadlineLocked()
+
+	prevLogIndex, entries := input.GetPrevLogIndex(), input.GetEntries()
+	if prevLogIndex < s.snapshotIndex {
+		// entries up to the snapshot are committed, so they match it
+		skipped := min(s.snapshotIndex-prevLogIndex, int64(len(entries)))
+		prevLogIndex, entries = prevLogIndex+skipped, entries[skipped:]
+	}
+	if prevLogIndex > s.lastIndexLocked() {
+		output.MatchedIndex = s.lastIndexLocked()
+		return output, nil
+	}
+	if prevLogIndex > s.snapshotIndex && s.termLocked(prevLogIndex) != input.GetPrevLogTerm() {
+		// skip back over the whole conflicting term
+		conflictTerm := s.termLocked(prevLogIndex)
+		index := prevLogIndex - 1
+		for index > s.snapshotIndex && s.termLocked(index) == conflictTerm {
+			index--
+		}
+		output.MatchedIndex = index
+		return output, nil
+	}
+
+	var firstChanged int64
+	for i, entry := range entries {
+		index := prevLogIndex + int64(i) + 1
+		if index <= s.lastIndexLocked() {
+			if s.termLocked(index) == entry.GetTerm() {
+				continue
+			}
+			s.log = s.log[:index-s.snapshotIndex-1]
+		}
+		s.log = append(s.log, entry)
+		if firstChanged == 0 {
+			firstChanged = index
+		}
+	}
+	if firstChanged > 0 {
+		s.persistEntriesLocked(firstChanged)
+	}
+
+	lastNewIndex := input.GetPrevLogIndex() + int64(len(input.GetEntries()))
+	// a stale or reordered call may cover less than this server already
+	// knows to be committed, and the commit index never moves back
+	if input.GetLeaderCommit() > s.commitIndex {
+		s.commitIndex = max(s.commitIndex, min(input.GetLeaderCommit(), lastNewIndex))
+		s.applyLocked()
+	}
+	output.Success = true
+	output.MatchedIndex = lastNewIndex
+	return output, nil
+}
+
+// InstallSnapshot replaces this server's log and state with the leader's
+// snapshot, received in chunks sent in order
+func (s *RaftSurfstore) InstallSnapshot(ctx context.Context, input *InstallSnapshotInput) (*InstallSnapshotOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.isCrashed {
+		return nil, crashedError()
+	}
+	output := &InstallSnapshotOutput{Term: s.term}
+	if input.GetTerm() < s.term {
+		return output, nil
+	}
+	if input.GetTerm() > s.term || s.role != raftFollower {
+		s.becomeFollowerLocked(input.GetTerm())
+		output.Term = s.term
+	}
+	s.leaderId = input.GetLeaderId()
+	s.resetElectionDeadlineLocked()
+
+	if input.GetOffset() == 0 {
+		s.pendingSnapshot = &InstallSnapshotInput{
+			LastIncludedIndex: input.GetLastIncludedIndex(),
+			LastIncludedTerm:  input.GetLastIncludedTerm(),
+		}
+	}
+	pending := s.pendingSnapshot
+	if pending == nil || pending.GetLastIncludedIndex() != input.GetLastIncludedIndex() ||
+		pending.GetLastIncludedTerm() != input.GetLastIncludedTerm() || int64(len(pending.GetData())) != input.GetOffset() {
+		s.pendingSnapshot = nil
+		return output, nil
+	}
+	pending.Data = append(pending.Data, input.GetData()...)
+	output.Success = true
+	if !input.GetDone() {
+		return output, nil
+	}
+	s.pendingSnapshot = nil
+
+	index, term := pending.GetLastIncludedIndex(), pending.GetLastIncludedTerm()
+	if index <= s.lastApplied {
+		// this server already applied everything the snapshot holds
+		return output, nil
+	}
+	state := &MetaSnapshot{}
+	if err := proto.Unmarshal(pending.GetData(), state); err != nil {
+		return nil, err
+	}
+	// entries after the snapshot are kept if the log agrees with it
+	if index <= s.lastIndexLocked() && s.termLocked(index) == term {
+		s.log = append([]*UpdateOperation{}, s.log[index-s.snapshotIndex:]...)
+	} else {
+		s.log = []*UpdateOperation{}
+	}
+	s.snapshotIndex, s.snapshotTerm, s.snapshot = index, term, pending.GetData()
+	s.metaStore.Restore(metaSnapshotHistories(state))
+	s.commitIndex, s.lastApplied = index, index
+	if s.raftLog != nil {
+		snapshot := &RaftSnapshot{LastIncludedIndex: index, LastIncludedTerm: term, State: state}
+		if err := s.raftLog.Compact(snapshot, s.log); err != nil {
+			panic("failed to persist raft snapshot: " + err.Error())
+		}
+	}
+	return output, nil
+}
+
+func (s *RaftSurfstore) RequestVote(ctx context.Context, input *RequestVoteInput) (*RequestVoteOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.isCrashed {
+		return nil, crashedError()
+	}
+	if input.GetTerm() > s.term {
+		s.becomeFollowerLocked(input.GetTerm())
+	}
+	output := &RequestVoteOutput{Term: s.term}
+	if input.GetTerm() < s.term {
+		return output, nil
+	}
+	lastLogIndex, lastLogTerm := s.lastLogLocked()
+	upToDate := input.GetLastLogTerm() > lastLogTerm ||
+		(input.GetLastLogTerm() == lastLogTerm && input.GetLastLogIndex() >= lastLogIndex)
+	if (s.votedFor == RAFT_NO_VOTE || s.votedFor == input.GetCandidateId()) && upToDate {
+		s.votedFor = input.GetCandidateId()
+		s.persistStateLocked()
+		s.resetElectionDeadlineLocked()
+		output.VoteGranted = true
+	}
+	return output, nil
+}
+
+// Start runs the election and heartbeat timers until Stop is called
+func (s *RaftSurfstore) Start() {
+	go func() {
+		ticker := time.NewTicker(RAFT_TICK_INTERVAL)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+				s.tick()
+			}
+		}
+	}()
+}
+
+// Stop ends the timers and refuses every later request, then closes the
+// persisted state
+func (s *RaftSurfstore) Stop() {
+	close(s.stop)
+	s.mu.Lock()
+	s.isCrashed = true
+	s.role = raftFollower
+	s.failWaitersLocked(commitUnknownError())
+	if s.raftLog != nil {
+		s.raftLog.Close()
+		s.raftLog = nil
+	}
+	s.mu.Unlock()
+	s.peerConns.Close()
+}
+
+// Crash makes the server stop taking part in Raft and refuse every request,
+// while keeping its term, vote and log as if they were on stable storage
+func (s *RaftSurfstore) Crash() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.isCrashed = true
+	s.role = raftFollower
+	s.failWaitersLocked(commitUnknownError())
+}
+
+// Restart brings a crashed server back as a follower
+func (s *RaftSurfstore) Restart() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.isCrashed = false
+	s.role = raftFollower
+	s.resetElectionDeadlineLocked()
+}
+
+// SetUnreachable drops every Raft RPC this server sends to the given peers
+func (s *RaftSurfstore) SetUnreachable(peerIds []int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.unreachable = make(map[int64]bool)
+	for _, id := range peerIds {
+		s.unreachable[id] = true
+	}
+}
+
+// IsLeader reports whether the server currently believes it leads, and in which term
+func (s *RaftSurfstore) IsLeader() (bool, int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.role == raftLeader && !s.isCrashed, s.term
+}
+
+func (s *RaftSurfstore) tick() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.isCrashed {
+		return
+	}
+	now := time.Now()
+	if s.role == raftLeader {
+		if now.Sub(s.lastHeartbeat) >= RAFT_HEARTBEAT_INTERVAL {
+			s.broadcastLocked()
+		}
+	} else if now.After(s.electionDeadline) {
+		s.startElectionLocked()
+	}
+}
+
+func (s *RaftSurfstore) startElectionLocked() {
+	s.term++
+	s.role = raftCandidate
+	s.votedFor = s.id
+	s.leaderId = RAFT_NO_VOTE
+	s.persistStateLocked()
+	s.resetElectionDeadlineLocked()
+
+	electionTerm := s.term
+	lastLogIndex, lastLogTerm := s.lastLogLocked()
+	input := &RequestVoteInput{
+		Term:         electionTerm,
+		CandidateId:  s.id,
+		LastLogIndex: lastLogIndex,
+		LastLogTerm:  lastLogTerm,
+	}
+	votes := 1
+	if votes > len(s.peers)/2 {
+		s.becomeLeaderLocked()
+		return
+	}
+	for peerId := range s.peers {
+		if int64(peerId) == s.id {
+			continue
+		}
+		go func(peerId int64) {
+			output, err := s.requestVote(peerId, input)
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if output.GetTerm() > s.term {
+				s.becomeFollowerLocked(output.GetTerm())
+				return
+			}
+			if s.term != electionTerm || s.role != raftCandidate || !output.GetVoteGranted() {
+				return
+			}
+			votes++
+			if votes > len(s.peers)/2 {
+				s.becomeLeaderLocked()
+			}
+		}(int64(peerId))
+	}
+}
+
+func (s *RaftSurfstore) becomeLeaderLocked() {
+	s.role = raftLeader
+	s.leaderId = s.id
+	for peerId := range s.peers {
+		s.nextIndex[peerId] = s.lastIndexLocked() + 1
+		s.matchIndex[peerId] = 0
+	}
+	// committing an entry of the new term also commits everything before it
+	s.appendLocked(&UpdateOperation{Term: s.term})
+	s.broadcastLocked()
+	s.advanceCommitLocked()
+}
+
+func (s *RaftSurfstore) becomeFollowerLocked(term int64) {
+	if term > s.term {
+		s.term = term
+		s.votedFor = RAFT_NO_VOTE
+		s.persistStateLocked()
+	}
+	if s.role == raftLeader {
+		s.failWaitersLocked(commitUnknownError())
+	}
+	s.role = raftFollower
+}
+
+func (s *RaftSurfstore) appendLocked(entry *UpdateOperation) int64 {
+	s.log = append(s.log, entry)
+	index := s.lastIndexLocked()
+	s.persistEntriesLocked(index)
+	s.matchIndex[s.id] = index
+	return index
+}
+
+// broadcastLocked starts replicating to every peer that is not already busy
+func (s *RaftSurfstore) broadcastLocked() {
+	s.lastHeartbeat = time.Now()
+	for peerId := range s.peers {
+		if int64(peerId) == s.id || s.replicating[peerId] {
+			continue
+		}
+		s.replicating[peerId] = true
+		go s.replicateTo(int64(peerId))
+	}
+}
+
+// replicateTo sends AppendEntries to one peer until it has caught up with
+// the leader's log or a call fails
+func (s *RaftSurfstore) replicateTo(peerId int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer func() { s.replicating[peerId] = false }()
+	for s.role == raftLeader && !s.isCrashed {
+		if s.nextIndex[peerId] <= s.snapshotIndex {
+			// the entries the peer needs next were compacted away
+			if !s.sendSnapshotLocked(peerId) {
+				return
+			}
+			continue
+		}
+		prevLogIndex := s.nextIndex[peerId] - 1
+		// copy the entries, a later truncation must not change them mid-send
+		lastIndex := min(s.lastIndexLocked(), prevLogIndex+RAFT_MAX_APPEND_ENTRIES)
+		input := &AppendEntryInput{
+			Term:         s.term,
+			LeaderId:     s.id,
+			PrevLogIndex: prevLogIndex,
+			PrevLogTerm:  s.termLocked(prevLogIndex),
+			Entries:      append([]*UpdateOperation{}, s.log[prevLogIndex-s.snapshotIndex:lastIndex-s.snapshotIndex]...),
+			LeaderCommit: s.commitIndex,
+		}
+
+		s.mu.Unlock()
+		output, err := s.appendEntries(peerId, input)
+		s.mu.Lock()
+		if err != nil {
+			return
+		}
+		if output.GetTerm() > s.term {
+			s.becomeFollowerLocked(output.GetTerm())
+			return
+		}
+		if s.role != raftLeader || s.term != input.GetTerm() {
+			return
+		}
+		if output.GetSuccess() {
+			s.matchIndex[peerId] = max(s.matchIndex[peerId], output.GetMatchedIndex())
+			s.nextIndex[peerId] = s.matchIndex[peerId] + 1
+			s.advanceCommitLocked()
+			if s.nextIndex[peerId] > s.lastIndexLocked() {
+				return
+			}
+			continue
+		}
+		s.nextIndex[peerId] = max(1, min(s.nextIndex[peerId]-1, output.GetMatchedIndex()+1))
+	}
+}
+
+// sendSnapshotLocked sends the latest snapshot to a peer chunk by chunk and
+// reports whether the peer installed it
+func (s *RaftSurfstore) sendSnapshotLocked(peerId int64) bool {
+	term, index, snapshotTerm, data := s.term, s.snapshotIndex, s.snapshotTerm, s.snapshot
+	for offset := 0; ; offset += RAFT_SNAPSHOT_CHUNK_SIZE {
+		end := min(len(data), offset+RAFT_SNAPSHOT_CHUNK_SIZE)
+		input := &InstallSnapshotInput{
+			Term:              term,
+			LeaderId:          s.id,
+			LastIncludedIndex: index,
+			LastIncludedTerm:  snapshotTerm,
+			Offset:            int64(offset),
+			Data:              data[offset:end],
+			Done:              end == len(data),
+		}
+
+		s.mu.Unlock()
+		output, err := s.installSnapshot(peerId, input)
+		s.mu.Lock()
+		if err != nil {
+			return false
+		}
+		if output.GetTerm() > s.term {
+			s.becomeFollowerLocked(output.GetTerm())
+			return false
+		}
+		if s.role != raftLeader || s.term != term || s.isCrashed || !output.GetSuccess() {
+			return false
+		}
+		if input.GetDone() {
+			s.matchIndex[peerId] = max(s.matchIndex[peerId], index)
+			s.nextIndex[peerId] = s.matchIndex[peerId] + 1
+			s.advanceCommitLocked()
+			return true
+		}
+	}
+}
+
+// advanceCommitLocked commits the highest entry of the current term stored
+// on a majority, together with everything before it
+func (s *RaftSurfstore) advanceCommitLocked() {
+	for index := s.lastIndexLocked(); index > s.commitIndex; index-- {
+		if s.termLocked(index) != s.term {
+			break
+		}
+		count := 0
+		for peerId := range s.peers {
+			if s.matchIndex[peerId] >= index {
+				count++
+			}
+		}
+		if count > len(s.peers)/2 {
+			s.commitIndex = index
+			s.applyLocked()
+			return
+		}
+	}
+}
+
+// applyLocked feeds committed entries to the MetaStore in log order and
+// answers the clients waiting on them
+func (s *RaftSurfstore) applyLocked() {
+	for s.lastApplied < s.commitIndex {
+		s.lastApplied++
+		entry := s.entryLocked(s.lastApplied)
+		result := &raftResult{}
+		if entry.GetFileMetaData() != nil {
+			result.version, result.err = s.metaStore.ApplyUpdate(entry.GetFileMetaData())
+		}
+		if waiter, ok := s.waiters[s.lastApplied]; ok {
+			delete(s.waiters, s.lastApplied)
+			if waiter.term != entry.GetTerm() {
+				// the client's entry was overwritten by another leader, so
+				// it can safely be sent to the new one
+				result = &raftResult{err: s.notLeaderErrorLocked()}
+			}
+			waiter.result <- result
+		}
+	}
+	if s.lastApplied-s.snapshotIndex >= s.snapshotInterval {
+		s.takeSnapshotLocked()
+	}
+}
+
+// takeSnapshotLocked replaces the applied part of the log with a snapshot
+// of the MetaStore
+func (s *RaftSurfstore) takeSnapshotLocked() {
+	state := s.metaStore.Snapshot()
+	data, err := proto.Marshal(state)
+	if err != nil {
+		panic("failed to take raft snapshot: " + err.Error())
+	}
+	index, term := s.lastApplied, s.termLocked(s.lastApplied)
+	s.log = append([]*UpdateOperation{}, s.log[index-s.snapshotIndex:]...)
+	s.snapshotIndex, s.snapshotTerm, s.snapshot = index, term, data
+	if s.raftLog != nil {
+		snapshot := &RaftSnapshot{LastIncludedIndex: index, LastIncludedTerm: term, State: state}
+		if err := s.raftLog.Compact(snapshot, s.log); err != nil {
+			panic("failed to persist raft snapshot: " + err.Error())
+		}
+	}
+}
+
+func (s *RaftSurfstore) failWaitersLocked(err error) {
+	for index, waiter := range s.waiters {
+		waiter.result <- &raftResult{err: err}
+		delete(s.waiters, index)
+	}
+}
+
+// confirmLeadership makes reads linearizable: the leader checks that a
+// majority still follows it, then waits until its state machine caught up
+// with everything committed before the read arrived
+func (s *RaftSurfstore) confirmLeadership(ctx context.Context) error {
+	s.mu.Lock()
+	if s.isCrashed {
+		s.mu.Unlock()
+		return crashedError()
+	}
+	if s.role != raftLeader {
+		err := s.notLeaderErrorLocked()
+		s.mu.Unlock()
+		return err
+	}
+	term := s.term
+	input := &AppendEntryInput{Term: term, LeaderId: s.id, PrevLogIndex: s.lastIndexLocked() + 1}
+	s.mu.Unlock()
+
+	acks := make(chan bool, len(s.peers))
+	for peerId := range s.peers {
+		if int64(peerId) == s.id {
+			continue
+		}
+		go func(peerId int64) {
+			// the probe always fails the log check; it only asks whether the
+			// peer still accepts this leader's term
+			output, err := s.appendEntries(peerId, input)
+			acks <- err == nil && output.GetTerm() == term
+		}(int64(peerId))
+	}
+	confirmed := 1
+	for i := 0; i < len(s.peers)-1 && confirmed <= len(s.peers)/2; i++ {
+		if <-acks {
+			confirmed++
+		}
+	}
+	if confirmed <= len(s.peers)/2 {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.notLeaderErrorLocked()
+	}
+
+	ticker := time.NewTicker(RAFT_TICK_INTERVAL)
+	defer ticker.Stop()
+	for {
+		s.mu.Lock()
+		if s.isCrashed {
+			s.mu.Unlock()
+			return crashedError()
+		}
+		if s.role != raftLeader || s.term != term {
+			err := s.notLeaderErrorLocked()
+			s.mu.Unlock()
+			return err
+		}
+		ready := s.commitIndex > 0 && s.termLocked(s.commitIndex) == term && s.lastApplied >= s.commitIndex
+		s.mu.Unlock()
+		if ready {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *RaftSurfstore) appendEntries(peerId int64, input *AppendEntryInput) (*AppendEntryOutput, error) {
+	conn, err := s.peerConn(peerId)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), RAFT_RPC_TIMEOUT)
+	defer cancel()
+	return NewRaftSurfstoreClient(conn).AppendEntries(ctx, input)
+}
+
+func (s *RaftSurfstore) installSnapshot(peerId int64, input *InstallSnapshotInput) (*InstallSnapshotOutput, error) {
+	conn, err := s.peerConn(peerId)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), RAFT_RPC_TIMEOUT)
+	defer cancel()
+	return NewRaftSurfstoreClient(conn).InstallSnapshot(ctx, input)
+}
+
+func (s *RaftSurfstore) requestVote(peerId int64, input *RequestVoteInput) (*RequestVoteOutput, error) {
+	conn, err := s.peerConn(peerId)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), RAFT_RPC_TIMEOUT)
+	defer cancel()
+	return NewRaftSurfstoreClient(conn).RequestVote(ctx, input)
+}
+
+// peerConn returns a pooled connection to a peer, failing while the harness
+// has made the peer unreachable
+func (s *RaftSurfstore) peerConn(peerId int64) (*grpc.ClientConn, error) {
+	s.mu.Lock()
+	unreachable := s.unreachable[peerId] || s.isCrashed
+	s.mu.Unlock()
+	if unreachable {
+		return nil, status.Error(codes.Unavailable, "peer unreachable")
+	}
+	return s.peerConns.Get(s.peers[peerId])
+}
+
+func (s *RaftSurfstore) crashed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.isCrashed
+}
+
+func (s *RaftSurfstore) lastLogLocked() (int64, int64) {
+	return s.lastIndexLocked(), s.termLocked(s.lastIndexLocked())
+}
+
+func (s *RaftSurfstore) lastIndexLocked() int64 {
+	return s.snapshotIndex + int64(len(s.log))
+}
+
+// entryLocked returns the entry at a log index after the snapshot
+func (s *RaftSurfstore) entryLocked(index int64) *UpdateOperation {
+	return s.log[index-s.snapshotIndex-1]
+}
+
+// termLocked returns the term of the entry at a log index, which must not
+// lie before the snapshot. Index 0 precedes every entry and has term 0.
+func (s *RaftSurfstore) termLocked(index int64) int64 {
+	if index == s.snapshotIndex {
+		return s.snapshotTerm
+	}
+	return s.entryLocked(index).GetTerm()
+}
+
+func (s *RaftSurfstore) resetElectionDeadlineLocked() {
+	timeout := RAFT_ELECTION_TIMEOUT_MIN +
+		time.Duration(rand.Int63n(int64(RAFT_ELECTION_TIMEOUT_MAX-RAFT_ELECTION_TIMEOUT_MIN)))
+	s.electionDeadline = time.Now().Add(timeout)
+}
+
+// notLeaderErrorLocked tells the client where the leader is, when known
+func (s *RaftSurfstore) notLeaderErrorLocked() error {
+	st := status.New(codes.FailedPrecondition, ErrNotLeader.Error())
+	info := &errdetails.ErrorInfo{Reason: RAFT_NOT_LEADER_REASON}
+	if s.leaderId >= 0 && s.leaderId != s.id {
+		info.Metadata = map[string]string{RAFT_LEADER_ADDR_KEY: s.peers[s.leaderId]}
+	}
+	if detailed, err := st.WithDetails(info); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+// crashedError refuses a request before the server acted on it
+func crashedError() error {
+	return status.Error(codes.Unavailable, ErrServerCrashed.Error())
+}
+
+// commitUnknownError answers a client whose update was appended to the log
+// before the server crashed or lost its leadership. The update may still
+// be committed by the next leader.
+func commitUnknownError() error {
+	return status.Error(codes.Aborted, ErrCommitUnknown.Error())
+}
+
+// persistStateLocked saves term and vote before the server acts on them
+func (s *RaftSurfstore) persistStateLocked() {
+	if s.raftLog == nil {
+		return
+	}
+	if err := s.raftLog.SaveState(s.term, s.votedFor); err != nil {
+		// acting on state that is not durable could break Raft's guarantees
+		panic("failed to persist raft state: " + err.Error())
+	}
+}
+
+// persistEntriesLocked saves the log from index firstIndex on, which
+// replaces whatever the persisted log held from there
+func (s *RaftSurfstore) persistEntriesLocked(firstIndex int64) {
+	if s.raftLog == nil {
+		return
+	}
+	if err := s.raftLog.Append(firstIndex, s.log[firstIndex-s.snapshotIndex-1:]); err != nil {
+		panic("failed to persist raft log: " + err.Error())
+	}
+}
+
+// This line guarantees all method for RaftSurfstore are implemented
+var _ MetaStoreInterface = new(RaftSurfstore)
+
+// NewRaftServer creates replica id of the cluster whose addresses are peers.
+// With a non-empty stateDir the Raft term, vote, log and snapshots survive
+// restarts. The log is compacted into a snapshot every snapshotInterval
+// applied entries, DEFAULT_RAFT_SNAPSHOT_INTERVAL if it is not positive.
+func NewRaftServer(id int64, peers []string, blockStoreAddrs []string, stateDir string, snapshotInterval int) (*RaftSurfstore, error) {
+	if snapshotInterval <= 0 {
+		snapshotInterval = DEFAULT_RAFT_SNAPSHOT_INTERVAL
+	}
+	s := &RaftSurfstore{
+		id:               id,
+		peers:            peers,
+		metaStore:        NewMetaStore(blockStoreAddrs),
+		role:             raftFollower,
+		votedFor:         RAFT_NO_VOTE,
+		log:              []*UpdateOperation{},
+		leaderId:         RAFT_NO_VOTE,
+		snapshotInterval: int64(snapshotInterval),
+		nextIndex:        make([]int64, len(peers)),
+		matchIndex:       make([]int64, len(peers)),
+		replicating:      make([]bool, len(peers)),
+		waiters:          make(map[int64]*raftWaiter),
+		unreachable:      make(map[int64]bool),
+		peerConns:        NewConnPool(1),
+		stop:             make(chan struct{}),
+	}
+	if stateDir != "" {
+		raftLog, state, entries, snapshot, err := OpenRaftLog(stateDir)
+		if err != nil {
+			return nil, err
+		}
+		s.raftLog = raftLog
+		s.term = state.GetTerm()
+		s.votedFor = state.GetVotedFor()
+		s.log = entries
+		if index := snapshot.GetLastIncludedIndex(); index > 0 {
+			data, err := proto.Marshal(snapshot.GetState())
+			if err != nil {
+				raftLog.Close()
+				return nil, err
+			}
+			s.snapshotIndex, s.snapshotTerm, s.snapshot = index, snapshot.GetLastIncludedTerm(), data
+			s.metaStore.Restore(metaSnapshotHistories(snapshot.GetState()))
+			s.commitIndex, s.lastApplied = index, index
+		}
+	}
+	s.resetElectionDeadlineLocked()
+	return s, nil
+}
@@ -0,0 +1,362 @@
+package surfstore
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testRaftLeaderTimeout = 5 * time.Second
+
+func startRaftCluster(t *testing.T, n int) *raftCluster {
+	t.Helper()
+	return startDurableRaftCluster(t, n, "", 0)
+}
+
+func startDurableRaftCluster(t *testing.T, n int, stateDir string, snapshotInterval int) *raftCluster {
+	t.Helper()
+	c, err := newRaftCluster(n, stateDir, snapshotInterval)
+	if err != nil {
+		t.Fatalf("start cluster: %v", err)
+	}
+	t.Cleanup(c.Shutdown)
+	return c
+}
+
+func waitForLeader(t *testing.T, c *raftCluster) int {
+	t.Helper()
+	leader, err := c.WaitForLeader(testRaftLeaderTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leader
+}
+
+// testRaftClient fails fast, so a test spends its time on faults rather
+// than on deadlines
+func testRaftClient(c *raftCluster) *RPCClient {
+	client := c.Client("", 4096)
+	client.Options = RPCOptions{
+		MetaTimeout:  time.Second,
+		ListTimeout:  time.Second,
+		BlockTimeout: time.Second,
+		MaxRetries:   2,
+		BaseBackoff:  10 * time.Millisecond,
+		MaxBackoff:   100 * time.Millisecond,
+	}
+	return &client
+}
+
+// testVersion is a version of an empty file, told apart from other
+// versions by its mtime
+func testVersion(fileName string, version int32, tag int64) *FileMetaData {
+	return &FileMetaData{Filename: fileName, Version: version, Mtime: tag}
+}
+
+func commitVersion(t *testing.T, client *RPCClient, fileMetaData *FileMetaData) {
+	t.Helper()
+	var latestVersion int32
+	if err := client.UpdateFile(fileMetaData, &latestVersion); err != nil {
+		t.Fatalf("UpdateFile %q version %d: %v", fileMetaData.GetFilename(), fileMetaData.GetVersion(), err)
+	}
+	if latestVersion != fileMetaData.GetVersion() {
+		t.Fatalf("UpdateFile %q version %d: got version %d", fileMetaData.GetFilename(), fileMetaData.GetVersion(), latestVersion)
+	}
+}
+
+// waitForHistory waits until replica i applied want as the history of fileName
+func waitForHistory(t *testing.T, c *raftCluster, i int, fileName string, want []*FileMetaData) {
+	t.Helper()
+	deadline := time.Now().Add(testRaftLeaderTimeout)
+	var got []*FileMetaData
+	for time.Now().Before(deadline) {
+		history, err := c.Servers[i].metaStore.GetFileHistory(context.Background(), &FileName{Filename: fileName})
+		if err == nil {
+			got = history.GetVersions()
+			if sameVersions(got, want) {
+				return
+			}
+		}
+		time.Sleep(RAFT_TICK_INTERVAL)
+	}
+	t.Fatalf("replica %d: history of %q is %v, want %v", i, fileName, versionTags(got), versionTags(want))
+}
+
+func sameVersions(a, b []*FileMetaData) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].GetVersion() != b[i].GetVersion() || a[i].GetMtime() != b[i].GetMtime() {
+			return false
+		}
+	}
+	return true
+}
+
+func versionTags(versions []*FileMetaData) []string {
+	tags := []string{}
+	for _, fileMetaData := range versions {
+		tags = append(tags, fmt.Sprintf("%d:%d", fileMetaData.GetVersion(), fileMetaData.GetMtime()))
+	}
+	return tags
+}
+
+func TestRaftWaitForLeaderIgnoresPartitionedLeader(t *testing.T) {
+	c := startRaftCluster(t, 3)
+	leader := waitForLeader(t, c)
+	_, oldTerm := c.Servers[leader].IsLeader()
+
+	others := []int{}
+	for i := range c.Servers {
+		if i != leader {
+			others = append(others, i)
+		}
+	}
+	c.Partition([]int{leader}, others)
+	newLeader := waitForLeader(t, c)
+	if newLeader == leader {
+		t.Fatalf("WaitForLeader returned replica %d, which was partitioned away", leader)
+	}
+	if _, term := c.Servers[newLeader].IsLeader(); term <= oldTerm {
+		t.Fatalf("new leader %d leads term %d, not after term %d", newLeader, term, oldTerm)
+	}
+}
+
+func TestRaftCommittedVersionsSurviveLeaderCrash(t *testing.T) {
+	c := startRaftCluster(t, 3)
+	client := testRaftClient(c)
+	leader := waitForLeader(t, c)
+	commitVersion(t, client, testVersion("a", 1, 1))
+
+	c.Crash(leader)
+	waitForLeader(t, c)
+	fileInfoMap := make(map[string]*FileMetaData)
+	if err := client.GetFileInfoMap(&fileInfoMap); err != nil {
+		t.Fatal(err)
+	}
+	if got := fileInfoMap["a"]; got.GetVersion() != 1 || got.GetMtime() != 1 {
+		t.Fatalf("after the leader crashed the server holds %v", got)
+	}
+	commitVersion(t, client, testVersion("a", 2, 2))
+
+	// the old leader catches up once it is back
+	c.Restart(leader)
+	waitForHistory(t, c, leader, "a", []*FileMetaData{testVersion("a", 1, 1), testVersion("a", 2, 2)})
+}
+
+func TestRaftPartitionedLeaderCannotCommit(t *testing.T) {
+	c := startRaftCluster(t, 5)
+	client := testRaftClient(c)
+	leader := waitForLeader(t, c)
+	commitVersion(t, client, testVersion("a", 1, 1))
+
+	// the old leader keeps one follower, the other three elect a new leader
+	minority := []int{leader, (leader + 1) % 5}
+	majority := []int{(leader + 2) % 5, (leader + 3) % 5, (leader + 4) % 5}
+	c.Partition(minority, majority)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	if v, err := c.Servers[leader].UpdateFile(ctx, testVersion("a", 2, 20)); err == nil {
+		t.Fatalf("the partitioned leader committed version %d", v.GetVersion())
+	}
+	// an update sent to the old leader may still commit, so the client
+	// does not move on by itself after a timeout
+	client.MetaStoreAddr = c.Addrs[waitForLeader(t, c)]
+	commitVersion(t, client, testVersion("a", 2, 2))
+
+	c.Heal()
+	want := []*FileMetaData{testVersion("a", 1, 1), testVersion("a", 2, 2)}
+	for i := range c.Servers {
+		waitForHistory(t, c, i, "a", want)
+	}
+}
+
+// TestRaftVersionsStayLinearizable races writers for the versions of one
+// file while replicas crash, restart from memory or disk, and get
+// partitioned, with snapshots compacting the logs. Every version UpdateFile
+// acknowledged must end up in the history exactly once, with the content of
+// the writer that won it, on every replica.
+func TestRaftVersionsStayLinearizable(t *testing.T) {
+	if testing.Short() {
+		t.Skip("fault injection takes seconds")
+	}
+	const replicas = 5
+	const writers = 4
+	const faultTime = 4 * time.Second
+	c := startDurableRaftCluster(t, replicas, t.TempDir(), 20)
+	waitForLeader(t, c)
+
+	var mu sync.Mutex
+	acked := make(map[int32]int64)
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			client := testRaftClient(c)
+			defer client.Close()
+			var current int32
+			for n := int64(1); ; n++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				tag := int64(w+1)<<32 | n
+				var latestVersion int32
+				err := client.UpdateFile(testVersion("shared", current+1, tag), &latestVersion)
+				if err == nil && latestVersion == current+1 {
+					mu.Lock()
+					if winner, ok := acked[latestVersion]; ok {
+						t.Errorf("version %d acknowledged to %x and %x", latestVersion, winner, tag)
+					}
+					acked[latestVersion] = tag
+					mu.Unlock()
+					current = latestVersion
+					continue
+				}
+				// lost the race, or the outcome is unknown: learn the latest version
+				fileInfoMap := make(map[string]*FileMetaData)
+				if err := client.GetFileInfoMap(&fileInfoMap); err == nil {
+					current = fileInfoMap["shared"].GetVersion()
+				}
+			}
+		}(w)
+	}
+
+	// crash, restart and partition replicas, never taking down a majority
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	crashed := make(map[int]bool)
+	for deadline := time.Now().Add(faultTime); time.Now().Before(deadline); {
+		switch rng.Intn(5) {
+		case 0:
+			if len(crashed) < (replicas-1)/2 {
+				i := rng.Intn(replicas)
+				if !crashed[i] {
+					c.Crash(i)
+					crashed[i] = true
+				}
+			}
+		case 1:
+			for i := range crashed {
+				c.Restart(i)
+				delete(crashed, i)
+				break
+			}
+		case 2:
+			perm := rng.Perm(replicas)
+			split := 1 + rng.Intn((replicas-1)/2)
+			c.Partition(perm[:split], perm[split:])
+		case 3:
+			c.Heal()
+		case 4:
+			i := rng.Intn(replicas)
+			if err := c.Reboot(i); err != nil {
+				t.Fatal(err)
+			}
+			delete(crashed, i)
+		}
+		time.Sleep(time.Duration(100+rng.Intn(300)) * time.Millisecond)
+	}
+	close(stop)
+	wg.Wait()
+
+	c.Heal()
+	for i := range crashed {
+		c.Restart(i)
+	}
+	waitForLeader(t, c)
+	client := testRaftClient(c)
+	defer client.Close()
+	var history []*FileMetaData
+	if err := client.GetFileHistory("shared", &history); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(acked) == 0 {
+		t.Fatal("no version was acknowledged")
+	}
+	for i, fileMetaData := range history {
+		if fileMetaData.GetVersion() != int32(i+1) {
+			t.Fatalf("history is %v, want versions 1 to %d in order", versionTags(history), len(history))
+		}
+	}
+	for version, tag := range acked {
+		if int(version) > len(history) || history[version-1].GetMtime() != tag {
+			t.Errorf("acknowledged version %d (%x) is missing from history %v", version, tag, versionTags(history))
+		}
+	}
+	for i := range c.Servers {
+		waitForHistory(t, c, i, "shared", history)
+	}
+	t.Logf("%d versions committed, %d acknowledged", len(history), len(acked))
+}
+
+func commitVersions(t *testing.T, client *RPCClient, fileName string, from, to int32) []*FileMetaData {
+	t.Helper()
+	versions := []*FileMetaData{}
+	for version := from; version <= to; version++ {
+		fileMetaData := testVersion(fileName, version, int64(version))
+		commitVersion(t, client, fileMetaData)
+		versions = append(versions, fileMetaData)
+	}
+	return versions
+}
+
+func TestRaftSnapshotCatchesUpLaggingReplica(t *testing.T) {
+	c := startDurableRaftCluster(t, 3, "", 5)
+	client := testRaftClient(c)
+	leader := waitForLeader(t, c)
+	lagging := (leader + 1) % 3
+	c.Partition([]int{leader, (leader + 2) % 3})
+	want := commitVersions(t, client, "a", 1, 20)
+
+	c.Servers[leader].mu.Lock()
+	snapshotIndex, logLength := c.Servers[leader].snapshotIndex, len(c.Servers[leader].log)
+	c.Servers[leader].mu.Unlock()
+	if snapshotIndex == 0 || logLength > 5 {
+		t.Fatalf("leader kept %d entries after a snapshot of %d, want a compacted log", logLength, snapshotIndex)
+	}
+
+	c.Heal()
+	waitForHistory(t, c, lagging, "a", want)
+	c.Servers[lagging].mu.Lock()
+	defer c.Servers[lagging].mu.Unlock()
+	if c.Servers[lagging].snapshotIndex == 0 {
+		t.Fatal("the lagging replica caught up without installing a snapshot")
+	}
+}
+
+func TestRaftRebootRecoversFromDisk(t *testing.T) {
+	c := startDurableRaftCluster(t, 3, t.TempDir(), 5)
+	client := testRaftClient(c)
+	waitForLeader(t, c)
+	want := commitVersions(t, client, "a", 1, 12)
+
+	// every replica loses its memory, one after another and then all at once
+	for i := range c.Servers {
+		if err := c.Reboot(i); err != nil {
+			t.Fatal(err)
+		}
+		waitForLeader(t, c)
+	}
+	want = append(want, commitVersions(t, client, "a", 13, 14)...)
+	for i := range c.Servers {
+		if err := c.Reboot(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitForLeader(t, c)
+	want = append(want, commitVersions(t, client, "a", 15, 15)...)
+	for i := range c.Servers {
+		waitForHistory(t, c, i, "a", want)
+	}
+}
@@ -0,0 +1,203 @@
+package surfstore
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	grpc "google.golang.org/grpc"
+)
+
+// raftCluster runs a whole replicated MetaStore inside one process on
+// loopback ports. Tests use it to crash, restart and partition replicas
+// while checking that versions handed out by UpdateFile stay linearizable.
+type raftCluster struct {
+	Addrs   []string
+	Servers []*RaftSurfstore
+
+	grpcServers      []*grpc.Server
+	stateDir         string
+	snapshotInterval int
+}
+
+// newRaftCluster starts n replicas without BlockStores, so only versions
+// without blocks can be committed. With a non-empty stateDir replica i
+// persists its state in stateDir/i.
+func newRaftCluster(n int, stateDir string, snapshotInterval int) (*raftCluster, error) {
+	c := &raftCluster{
+		Servers:          make([]*RaftSurfstore, n),
+		grpcServers:      make([]*grpc.Server, n),
+		stateDir:         stateDir,
+		snapshotInterval: snapshotInterval,
+	}
+	listeners := []net.Listener{}
+	for i := 0; i < n; i++ {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, err
+		}
+		listeners = append(listeners, ln)
+		c.Addrs = append(c.Addrs, ln.Addr().String())
+	}
+	for i, ln := range listeners {
+		if err := c.start(i, ln); err != nil {
+			for _, l := range listeners[i:] {
+				l.Close()
+			}
+			c.Shutdown()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func (c *raftCluster) start(i int, ln net.Listener) error {
+	stateDir := ""
+	if c.stateDir != "" {
+		stateDir = filepath.Join(c.stateDir, strconv.Itoa(i))
+	}
+	server, err := NewRaftServer(int64(i), c.Addrs, nil, stateDir, c.snapshotInterval)
+	if err != nil {
+		return err
+	}
+	grpcServer := grpc.NewServer(ServerKeepaliveOptions()...)
+	RegisterRaftSurfstoreServer(grpcServer, server)
+	RegisterMetaStoreServer(grpcServer, server)
+	go grpcServer.Serve(ln)
+	server.Start()
+	c.Servers[i] = server
+	c.grpcServers[i] = grpcServer
+	return nil
+}
+
+// Reboot stops replica i and starts it again from its state directory,
+// losing everything it only held in memory. The rebooted replica reaches
+// every other one, while the others keep any partition set before.
+func (c *raftCluster) Reboot(i int) error {
+	c.grpcServers[i].Stop()
+	c.Servers[i].Stop()
+	var ln net.Listener
+	var err error
+	// the port may take a moment to be released
+	for attempt := 0; attempt < 50; attempt++ {
+		if ln, err = net.Listen("tcp", c.Addrs[i]); err == nil {
+			break
+		}
+		time.Sleep(RAFT_TICK_INTERVAL)
+	}
+	if err != nil {
+		return err
+	}
+	if err := c.start(i, ln); err != nil {
+		ln.Close()
+		return err
+	}
+	return nil
+}
+
+func (c *raftCluster) Crash(i int) {
+	c.Servers[i].Crash()
+}
+
+func (c *raftCluster) Restart(i int) {
+	c.Servers[i].Restart()
+}
+
+// Partition splits the cluster so replicas only reach others in their own
+// group. Replicas not named in any group are cut off from everyone.
+func (c *raftCluster) Partition(groups ...[]int) {
+	groupOf := make(map[int]int)
+	for g, group := range groups {
+		for _, i := range group {
+			groupOf[i] = g
+		}
+	}
+	for i, server := range c.Servers {
+		unreachable := []int64{}
+		for j := range c.Servers {
+			gi, iOk := groupOf[i]
+			gj, jOk := groupOf[j]
+			if i != j && (!iOk || !jOk || gi != gj) {
+				unreachable = append(unreachable, int64(j))
+			}
+		}
+		server.SetUnreachable(unreachable)
+	}
+}
+
+// Heal removes every partition
+func (c *raftCluster) Heal() {
+	for _, server := range c.Servers {
+		server.SetUnreachable(nil)
+	}
+}
+
+// WaitForLeader returns the replica leading the highest term, once exactly
+// one live replica that reaches a majority leads it. A leader cut off from
+// the majority by a partition may still believe it leads and is ignored.
+func (c *raftCluster) WaitForLeader(timeout time.Duration) (int, error) {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		leader, leaderTerm, leaders := -1, int64(-1), 0
+		for i, server := range c.Servers {
+			isLeader, term := server.IsLeader()
+			if !isLeader || !c.reachesMajority(i) {
+				continue
+			}
+			if term > leaderTerm {
+				leader, leaderTerm, leaders = i, term, 1
+			} else if term == leaderTerm {
+				leaders++
+			}
+		}
+		if leaders == 1 {
+			return leader, nil
+		}
+		time.Sleep(RAFT_TICK_INTERVAL)
+	}
+	return -1, fmt.Errorf("no leader elected within %v", timeout)
+}
+
+// reachesMajority reports whether replica i can exchange Raft RPCs with a
+// majority of the cluster, itself included
+func (c *raftCluster) reachesMajority(i int) bool {
+	reachable := 0
+	for j := range c.Servers {
+		if i == j || (c.reaches(i, j) && c.reaches(j, i)) {
+			reachable++
+		}
+	}
+	return reachable > len(c.Servers)/2
+}
+
+// reaches reports whether replica i is live and may send to replica j
+func (c *raftCluster) reaches(i, j int) bool {
+	server := c.Servers[i]
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	return !server.isCrashed && !server.unreachable[int64(j)]
+}
+
+// Client returns an RPCClient that knows every replica of the cluster
+func (c *raftCluster) Client(baseDir string, blockSize int) RPCClient {
+	return NewSurfstoreRPCClient(strings.Join(c.Addrs, CONFIG_DELIMITER), baseDir, blockSize)
+}
+
+func (c *raftCluster) Shutdown() {
+	for _, grpcServer := range c.grpcServers {
+		if grpcServer != nil {
+			grpcServer.Stop()
+		}
+	}
+	for _, server := range c.Servers {
+		if server != nil {
+			server.Stop()
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package surfstore
+
+import (
+	"database/sql"
+	"fmt"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const createBlockTable string = `create table if not exists blocks (
+		hash TEXT PRIMARY KEY,
+		data BLOB
+	);`
+
+const insertBlock string = `INSERT OR IGNORE INTO blocks (hash, data) VALUES (?, ?);`
+
+const getBlockByHash string = `SELECT data FROM blocks WHERE hash = ?;`
+
+const hasBlockByHash string = `SELECT 1 FROM blocks WHERE hash = ?;`
+
+const getAllBlockHashes string = `SELECT hash FROM blocks;`
+
+// SQLiteBlockBackend keeps blocks in a single SQLite database file.
+type SQLiteBlockBackend struct {
+	db *sql.DB
+}
+
+func (sb *SQLiteBlockBackend) Get(hash string) (*Block, error) {
+	var blockData []byte
+	err := sb.db.QueryRow(getBlockByHash, hash).Scan(&blockData)
+	if err == sql.ErrNoRows {
+		return nil, ErrBlockNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &Block{BlockData: blockData, BlockSize: int32(len(blockData))}, nil
+}
+
+func (sb *SQLiteBlockBackend) Put(hash string, block *Block) error {
+	_, err := sb.db.Exec(insertBlock, hash, block.GetBlockData())
+	return err
+}
+
+func (sb *SQLiteBlockBackend) Has(hash string) (bool, error) {
+	var found int
+	err := sb.db.QueryRow(hasBlockByHash, hash).Scan(&found)
+	if err == sql.ErrNoRows {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (sb *SQLiteBlockBackend) Hashes() ([]string, error) {
+	rows, err := sb.db.Query(getAllBlockHashes)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	hashes := []string{}
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, hash)
+	}
+	return hashes, rows.Err()
+}
+
+func (sb *SQLiteBlockBackend) Close() error {
+	return sb.db.Close()
+}
+
+// This line guarantees all method for SQLiteBlockBackend are implemented
+var _ BlockBackend = new(SQLiteBlockBackend)
+
+func NewSQLiteBlockBackend(dbPath string) (*SQLiteBlockBackend, error) {
+	if dbPath == "" {
+		return nil, fmt.Errorf("sqlite block engine needs a database file")
+	}
+	db, err := sql.Open("sqlite3", "file:"+dbPath+"?_journal_mode=WAL&_synchronous=FULL&_busy_timeout=5000")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(createBlockTable); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &SQLiteBlockBackend{db: db}, nil
+}
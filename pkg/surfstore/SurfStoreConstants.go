@@ -1,13 +1,90 @@
 package surfstore
 
+import "time"
+
 const DEFAULT_META_FILENAME string = "index.db"
 
+// schema of index.db, stored in its user_version
+const META_SCHEMA_VERSION int = 1
+
+// Legacy hash lists of deleted and empty files. Versions carry explicit
+// deleted and size fields now, the markers are still written alongside
+// them for clients that predate those fields.
 const TOMBSTONE_HASHVALUE string = "0"
 const EMPTYFILE_HASHVALUE string = "-1"
 
+// Chunking schemes, recorded with every hash list
+const CHUNKING_FIXED string = "fixed"
+const CHUNKING_FASTCDC string = "fastcdc"
+
+// Conflict policies
+const CONFLICT_SERVER_WINS string = "server-wins"
+const CONFLICT_CLIENT_WINS string = "client-wins"
+const CONFLICT_NEWEST_WINS string = "newest-wins"
+const CONFLICT_KEEP_BOTH string = "keep-both"
+
+// Stages of a sync, reported by SyncError
+const SYNC_OP_SCAN string = "scan"
+const SYNC_OP_INDEX string = "index"
+const SYNC_OP_PUSH string = "push"
+const SYNC_OP_LIST string = "list"
+const SYNC_OP_PULL string = "pull"
+const SYNC_OP_CONFLICT string = "conflict"
+
+// When text files changed on both sides are merged: never, only when the
+// changes do not overlap, or always with overlapping changes marked
+const MERGE_OFF string = "off"
+const MERGE_CLEAN string = "clean"
+const MERGE_MARKERS string = "markers"
+
+// larger files, and versions more lines apart, are not merged
+const MAX_MERGE_SIZE int64 = 1 << 20
+const MAX_MERGE_EDITS int = 1000
+
+const MERGE_MARKER_LOCAL string = "<<<<<<< local"
+const MERGE_MARKER_SEPARATOR string = "======="
+const MERGE_MARKER_REMOTE string = ">>>>>>> server"
+
+// FastCDC min and max chunk sizes default to avg divided and multiplied by
+// this ratio. Normalization moves the mask by this many bits on either side
+// of the average chunk size.
+const FASTCDC_SIZE_RATIO int = 4
+const FASTCDC_NORMALIZATION int = 2
+
 const FILENAME_INDEX int = 0
 const VERSION_INDEX int = 1
 const HASH_LIST_INDEX int = 2
 
 const CONFIG_DELIMITER string = ","
 const HASH_DELIMITER string = " "
+
+const BLOCK_ENGINE_MEMORY string = "memory"
+const BLOCK_ENGINE_FILE string = "file"
+const BLOCK_ENGINE_SQLITE string = "sqlite"
+
+const META_WAL_FILENAME string = "meta.wal"
+const META_SNAPSHOT_FILENAME string = "meta.snapshot"
+const DEFAULT_SNAPSHOT_INTERVAL int = 1000
+
+// prefix of the files a pull assembles before renaming them into place
+const TEMPFILE_PREFIX string = ".surfstore-tmp-"
+
+// limits of filenames, in bytes, for a whole path and for each segment
+const MAX_FILENAME_LENGTH int = 4096
+const MAX_FILENAME_SEGMENT_LENGTH int = 255
+
+const DEFAULT_CONN_POOL_SIZE int = 2
+const KEEPALIVE_TIME time.Duration = 30 * time.Second
+const KEEPALIVE_TIMEOUT time.Duration = 10 * time.Second
+
+const DEFAULT_TRANSFER_WORKERS int = 8
+
+// Pull fetches this many blocks per worker before writing them out in order
+const PULL_WINDOW_PER_WORKER int = 4
+
+const DEFAULT_META_TIMEOUT time.Duration = 5 * time.Second
+const DEFAULT_LIST_TIMEOUT time.Duration = 30 * time.Second
+const DEFAULT_BLOCK_TIMEOUT time.Duration = 10 * time.Second
+const DEFAULT_MAX_RETRIES int = 4
+const DEFAULT_BASE_BACKOFF time.Duration = 100 * time.Millisecond
+const DEFAULT_MAX_BACKOFF time.Duration = 5 * time.Second
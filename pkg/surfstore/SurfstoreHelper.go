@@ -5,7 +5,6 @@ import (
 	"database/sql"
 	"encoding/hex"
 	"fmt"
-	"log"
 	"os"
 	"path/filepath"
 
@@ -25,8 +24,9 @@ func GetBlockHashString(blockData []byte) string {
 }
 
 /* File Path Related */
+// ConcatPath joins baseDir with a slash-separated path relative to it
 func ConcatPath(baseDir, fileDir string) string {
-	return baseDir + "/" + fileDir
+	return filepath.Join(baseDir, filepath.FromSlash(fileDir))
 }
 
 /*
@@ -42,54 +42,140 @@ const createTable string = `create table if not exists indexes (
 
 const insertTuple string = `INSERT INTO indexes (fileName, version, hashIndex, hashValue) VALUES (?, ?, ?, ?);`
 
+// fileinfo holds the per-file fields of FileMetaData, one row per file. It
+// lists every file, also those whose hash list is empty.
+const createFileInfoTable string = `create table if not exists fileinfo (
+		fileName TEXT PRIMARY KEY,
+		version INT,
+		chunkingScheme TEXT,
+		blockSize INT,
+		minChunkSize INT,
+		maxChunkSize INT,
+		directory INT,
+		mode INT,
+		mtime INT,
+		symlinkTarget TEXT,
+		deleted INT,
+		size INT
+	);`
+
+const insertFileInfo string = `INSERT INTO fileinfo (fileName, version, chunkingScheme, blockSize, minChunkSize, maxChunkSize, directory, mode, mtime, symlinkTarget, deleted, size) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`
+
+const createXattrTable string = `create table if not exists xattrs (
+		fileName TEXT,
+		name TEXT,
+		value BLOB
+	);`
+
+const insertXattr string = `INSERT INTO xattrs (fileName, name, value) VALUES (?, ?, ?);`
+
+const setSchemaVersion string = `PRAGMA user_version = %d;`
+
 //const testTuple string = `SELECT * FROM indexes`
 
-// WriteMetaFile writes the file meta map back to local metadata file index.db
+// WriteMetaFile writes the file meta map back to local metadata file index.db.
+// The new index is written next to index.db and renamed over it, so a crash
+// leaves either the old or the new index, never a partial one.
 func WriteMetaFile(fileMetas map[string]*FileMetaData, baseDir string) error {
-	// remove index.db file if it exists
 	outputMetaPath := ConcatPath(baseDir, DEFAULT_META_FILENAME)
-	// fmt.Printf("write meta file path: %v\n", outputMetaPath)
-	if _, err := os.Stat(outputMetaPath); err == nil {
-		e := os.Remove(outputMetaPath)
-		if e != nil {
-			log.Fatal("Error During Meta Write Back")
+	tmpMetaPath := ConcatPath(baseDir, TEMPFILE_PREFIX+DEFAULT_META_FILENAME)
+	// left behind by an interrupted write
+	if err := os.Remove(tmpMetaPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error during meta write back: %w", err)
+	}
+	if err := writeMetaDB(fileMetas, tmpMetaPath); err != nil {
+		os.Remove(tmpMetaPath)
+		return fmt.Errorf("error during meta write back: %w", err)
+	}
+	if err := os.Rename(tmpMetaPath, outputMetaPath); err != nil {
+		os.Remove(tmpMetaPath)
+		return fmt.Errorf("error during meta write back: %w", err)
+	}
+	d, err := os.Open(baseDir)
+	if err != nil {
+		return fmt.Errorf("error during meta write back: %w", err)
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("error during meta write back: %w", err)
+	}
+	return nil
+}
+
+// writeMetaDB creates a new metadata file at metaPath holding fileMetas,
+// all rows are written in one transaction
+func writeMetaDB(fileMetas map[string]*FileMetaData, metaPath string) error {
+	db, err := sql.Open("sqlite3", metaPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, statement := range []string{createTable, createFileInfoTable, createXattrTable, fmt.Sprintf(setSchemaVersion, META_SCHEMA_VERSION)} {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
 		}
 	}
-	db, err := sql.Open("sqlite3", outputMetaPath)
+
+	insertStatement, err := tx.Prepare(insertTuple)
 	if err != nil {
-		log.Fatal("Error During Meta Write Back")
+		return err
 	}
+	defer insertStatement.Close()
 
-	statement, err := db.Prepare(createTable)
+	insertFileInfoStatement, err := tx.Prepare(insertFileInfo)
 	if err != nil {
-		log.Fatal("Error During Meta Write Back")
+		return err
 	}
-	statement.Exec()
+	defer insertFileInfoStatement.Close()
 
-	insertStatement, err := db.Prepare(insertTuple)
+	insertXattrStatement, err := tx.Prepare(insertXattr)
 	if err != nil {
-		log.Fatal("Error During Meta Write Back")
+		return err
 	}
+	defer insertXattrStatement.Close()
 
 	for fileName, metaData := range fileMetas {
 		hashList := metaData.GetBlockHashList()
 		for index, value := range hashList {
 			_, err := insertStatement.Exec(fileName, metaData.GetVersion(), index, value)
 			if err != nil {
-				log.Fatal("Error During Meta Write Back")
+				return err
+			}
+		}
+		_, err := insertFileInfoStatement.Exec(fileName, metaData.GetVersion(), metaData.GetChunkingScheme(),
+			metaData.GetBlockSize(), metaData.GetMinChunkSize(), metaData.GetMaxChunkSize(), metaData.GetDirectory(),
+			metaData.GetMode(), metaData.GetMtime(), metaData.GetSymlinkTarget(), metaData.GetDeleted(), metaData.GetSize())
+		if err != nil {
+			return err
+		}
+		for name, value := range metaData.GetXattrs() {
+			_, err := insertXattrStatement.Exec(fileName, name, value)
+			if err != nil {
+				return err
 			}
 		}
 	}
-	return nil
+	return tx.Commit()
 }
 
 /*
 Reading Local Metadata File Related
 */
-const getDistinctFileName string = `SELECT DISTINCT fileName FROM indexes;`
+const getDistinctFileName string = `SELECT fileName FROM fileinfo;`
 
 const getTuplesByFileName string = `SELECT * FROM indexes WHERE fileName = ? ORDER BY hashIndex`
 
+const getFileInfoByFileName string = `SELECT version, chunkingScheme, blockSize, minChunkSize, maxChunkSize, directory, mode, mtime, symlinkTarget, deleted, size FROM fileinfo WHERE fileName = ?`
+
+const getXattrsByFileName string = `SELECT name, value FROM xattrs WHERE fileName = ?`
+
 // LoadMetaFromMetaFile loads the local metadata file into a file meta map.
 // The key is the file's name and the value is the file's metadata.
 // You can use this function to load the index.db file in this project.
@@ -102,24 +188,30 @@ func LoadMetaFromMetaFile(baseDir string) (fileMetaMap map[string]*FileMetaData,
 	}
 	db, err := sql.Open("sqlite3", metaFilePath)
 	if err != nil {
-		log.Fatal("Error When Opening Meta")
+		return nil, fmt.Errorf("error when opening meta: %w", err)
+	}
+	defer db.Close()
+
+	if err := migrateMetaFile(db); err != nil {
+		return nil, fmt.Errorf("error when migrating meta: %w", err)
 	}
 
 	rows, err := db.Query(getDistinctFileName)
 	if err != nil {
-		log.Fatal("Error When Getting Distinct FileName")
+		return nil, fmt.Errorf("error when getting distinct fileName: %w", err)
 	}
+	defer rows.Close()
 
 	for rows.Next() {
 		var fileName string
 		err := rows.Scan(&fileName)
 		if err != nil {
-			log.Fatal("Error Scanning FileName")
+			return nil, fmt.Errorf("error scanning fileName: %w", err)
 		}
 
 		tuples, err := db.Query(getTuplesByFileName, fileName)
 		if err != nil {
-			log.Fatal("Error Getting Tuples By FileName")
+			return nil, fmt.Errorf("error getting tuples by fileName: %w", err)
 		}
 		hashList := []string{}
 		var version int32
@@ -128,20 +220,171 @@ func LoadMetaFromMetaFile(baseDir string) (fileMetaMap map[string]*FileMetaData,
 			var value string
 			err := tuples.Scan(&fileName, &version, &index, &value)
 			if err != nil {
-				log.Fatal("Error Scanning Tupples")
+				tuples.Close()
+				return nil, fmt.Errorf("error scanning tuples: %w", err)
 			}
 			hashList = append(hashList, value)
 		}
+		tuples.Close()
 
-		fileMetaMap[fileName] = &FileMetaData{
+		fileMetaData := &FileMetaData{
 			Filename:      fileName,
 			Version:       version,
 			BlockHashList: hashList,
 		}
+		err = scanFileInfo(db, fileMetaData)
+		if err != nil {
+			return nil, fmt.Errorf("error getting file info by fileName: %w", err)
+		}
+		err = scanXattrs(db, fileMetaData)
+		if err != nil {
+			return nil, fmt.Errorf("error getting xattrs by fileName: %w", err)
+		}
+		fileMetaMap[fileName] = fileMetaData
 	}
 
-	return fileMetaMap, nil
+	return fileMetaMap, rows.Err()
+}
 
+// loadMetaFileCopy loads index.db like LoadMetaFromMetaFile but leaves it
+// untouched, a file written by an older client is migrated in a copy
+func loadMetaFileCopy(baseDir string) (map[string]*FileMetaData, error) {
+	data, err := os.ReadFile(ConcatPath(baseDir, DEFAULT_META_FILENAME))
+	if os.IsNotExist(err) {
+		return make(map[string]*FileMetaData), nil
+	} else if err != nil {
+		return nil, err
+	}
+	copyDir, err := os.MkdirTemp("", "surfstore-index-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(copyDir)
+	if err := os.WriteFile(ConcatPath(copyDir, DEFAULT_META_FILENAME), data, 0600); err != nil {
+		return nil, err
+	}
+	return LoadMetaFromMetaFile(copyDir)
+}
+
+// scanFileInfo fills the per-file fields of fileMetaData from its fileinfo
+// row
+func scanFileInfo(db *sql.DB, fileMetaData *FileMetaData) error {
+	err := db.QueryRow(getFileInfoByFileName, fileMetaData.GetFilename()).Scan(
+		&fileMetaData.Version, &fileMetaData.ChunkingScheme, &fileMetaData.BlockSize, &fileMetaData.MinChunkSize,
+		&fileMetaData.MaxChunkSize, &fileMetaData.Directory, &fileMetaData.Mode, &fileMetaData.Mtime,
+		&fileMetaData.SymlinkTarget, &fileMetaData.Deleted, &fileMetaData.Size)
+	return err
+}
+
+func scanXattrs(db *sql.DB, fileMetaData *FileMetaData) error {
+	rows, err := db.Query(getXattrsByFileName, fileMetaData.GetFilename())
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		var value []byte
+		if err := rows.Scan(&name, &value); err != nil {
+			return err
+		}
+		if fileMetaData.Xattrs == nil {
+			fileMetaData.Xattrs = make(map[string][]byte)
+		}
+		fileMetaData.Xattrs[name] = value
+	}
+	return rows.Err()
+}
+
+/*
+Migrating Local Metadata File Related
+*/
+const getSchemaVersion string = `PRAGMA user_version;`
+
+const getFileInfoColumns string = `SELECT name FROM pragma_table_info('fileinfo');`
+
+const addFileInfoColumn string = `ALTER TABLE fileinfo ADD COLUMN %s NOT NULL DEFAULT %s;`
+
+const insertMissingFileInfo string = `INSERT INTO fileinfo (fileName, version, chunkingScheme, blockSize, minChunkSize, maxChunkSize, directory, mode, mtime, symlinkTarget, deleted, size)
+	SELECT DISTINCT fileName, 0, '', 0, 0, 0, 0, 0, 0, '', 0, 0 FROM indexes WHERE fileName NOT IN (SELECT fileName FROM fileinfo);`
+
+const copyVersions string = `UPDATE fileinfo SET version =
+	(SELECT max(version) FROM indexes WHERE indexes.fileName = fileinfo.fileName);`
+
+const markLegacyTombstones string = `UPDATE fileinfo SET deleted = 1 WHERE fileName IN
+	(SELECT fileName FROM indexes WHERE hashIndex = 0 AND hashValue = '` + TOMBSTONE_HASHVALUE + `');`
+
+// fileInfoColumns lists the fileinfo columns after fileName with the value
+// rows written before the column existed get
+var fileInfoColumns = []struct {
+	name         string
+	definition   string
+	defaultValue string
+}{
+	{"version", "version INT", "0"},
+	{"chunkingScheme", "chunkingScheme TEXT", "''"},
+	{"blockSize", "blockSize INT", "0"},
+	{"minChunkSize", "minChunkSize INT", "0"},
+	{"maxChunkSize", "maxChunkSize INT", "0"},
+	{"directory", "directory INT", "0"},
+	{"mode", "mode INT", "0"},
+	{"mtime", "mtime INT", "0"},
+	{"symlinkTarget", "symlinkTarget TEXT", "''"},
+	{"deleted", "deleted INT", "0"},
+	{"size", "size INT", "0"},
+}
+
+// migrateMetaFile brings an index.db written by an older client to the
+// current schema, whose version is kept in user_version. Files from before
+// the version was recorded may lack the fileinfo and xattrs tables or some
+// fileinfo columns, keep versions only with the hash list and mark
+// deletions only in the hash list.
+func migrateMetaFile(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow(getSchemaVersion).Scan(&version); err != nil {
+		return err
+	}
+	if version >= META_SCHEMA_VERSION {
+		return nil
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, statement := range []string{createTable, createFileInfoTable, createXattrTable} {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+	columns := make(map[string]bool)
+	rows, err := tx.Query(getFileInfoColumns)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var column string
+		if err := rows.Scan(&column); err != nil {
+			rows.Close()
+			return err
+		}
+		columns[column] = true
+	}
+	rows.Close()
+	for _, column := range fileInfoColumns {
+		if columns[column.name] {
+			continue
+		}
+		if _, err := tx.Exec(fmt.Sprintf(addFileInfoColumn, column.definition, column.defaultValue)); err != nil {
+			return err
+		}
+	}
+	for _, statement := range []string{insertMissingFileInfo, copyVersions, markLegacyTombstones, fmt.Sprintf(setSchemaVersion, META_SCHEMA_VERSION)} {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
 }
 
 /*
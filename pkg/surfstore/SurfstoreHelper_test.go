@@ -0,0 +1,130 @@
+package surfstore
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// writeBaselineMetaFile writes index.db the way clients did before fileinfo
+// existed: the indexes table only, deletions and empty files marked in the
+// hash list
+func writeBaselineMetaFile(t *testing.T, baseDir string, hashLists map[string][]string, versions map[string]int32) {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ConcatPath(baseDir, DEFAULT_META_FILENAME))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(createTable); err != nil {
+		t.Fatal(err)
+	}
+	for fileName, hashList := range hashLists {
+		for index, value := range hashList {
+			if _, err := db.Exec(insertTuple, fileName, versions[fileName], index, value); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestMigrateMetaFileFromBaseline(t *testing.T) {
+	baseDir := t.TempDir()
+	writeBaselineMetaFile(t, baseDir, map[string][]string{
+		"a.txt":     {"h1", "h2"},
+		"empty.txt": {EMPTYFILE_HASHVALUE},
+		"gone.txt":  {TOMBSTONE_HASHVALUE},
+	}, map[string]int32{"a.txt": 3, "empty.txt": 1, "gone.txt": 2})
+
+	db, err := sql.Open("sqlite3", ConcatPath(baseDir, DEFAULT_META_FILENAME))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := migrateMetaFile(db); err != nil {
+		t.Fatalf("migrateMetaFile: %v", err)
+	}
+	var version int
+	if err := db.QueryRow(getSchemaVersion).Scan(&version); err != nil || version != META_SCHEMA_VERSION {
+		t.Fatalf("migrated schema version is %d (%v), want %d", version, err, META_SCHEMA_VERSION)
+	}
+	// a migrated file is left alone
+	if err := migrateMetaFile(db); err != nil {
+		t.Fatalf("migrateMetaFile again: %v", err)
+	}
+	db.Close()
+
+	fileMetaMap, err := LoadMetaFromMetaFile(baseDir)
+	if err != nil {
+		t.Fatalf("LoadMetaFromMetaFile: %v", err)
+	}
+	want := map[string]*FileMetaData{
+		"a.txt":     {Filename: "a.txt", Version: 3, BlockHashList: []string{"h1", "h2"}},
+		"empty.txt": {Filename: "empty.txt", Version: 1, BlockHashList: []string{EMPTYFILE_HASHVALUE}},
+		"gone.txt":  {Filename: "gone.txt", Version: 2, BlockHashList: []string{TOMBSTONE_HASHVALUE}, Deleted: true},
+	}
+	if len(fileMetaMap) != len(want) {
+		t.Fatalf("migrated index lists %v, want %v", fileMetaMap, want)
+	}
+	for fileName, fileMetaData := range want {
+		if !proto.Equal(fileMetaMap[fileName], fileMetaData) {
+			t.Fatalf("%s migrated to %v, want %v", fileName, fileMetaMap[fileName], fileMetaData)
+		}
+	}
+	if !IsEmptyFile(fileMetaMap["empty.txt"]) || !IsTombstone(fileMetaMap["gone.txt"]) {
+		t.Fatalf("the legacy markers were lost: %v", fileMetaMap)
+	}
+}
+
+func TestWriteMetaFileReplacesIndex(t *testing.T) {
+	baseDir := t.TempDir()
+	first := map[string]*FileMetaData{
+		"a.txt": {Filename: "a.txt", Version: 1, BlockHashList: []string{"h1"}, Size: 4, Mode: 0644},
+		"dir":   {Filename: "dir", Version: 1, Directory: true, Xattrs: map[string][]byte{"user.k": []byte("v")}},
+	}
+	if err := WriteMetaFile(first, baseDir); err != nil {
+		t.Fatalf("WriteMetaFile: %v", err)
+	}
+
+	// a temporary index left by an interrupted write is replaced
+	tmpMetaPath := ConcatPath(baseDir, TEMPFILE_PREFIX+DEFAULT_META_FILENAME)
+	if err := os.WriteFile(tmpMetaPath, []byte("torn"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	second := map[string]*FileMetaData{
+		"a.txt": {Filename: "a.txt", Version: 2, BlockHashList: []string{"h2", "h3"}, Size: 8, Mode: 0600},
+	}
+	if err := WriteMetaFile(second, baseDir); err != nil {
+		t.Fatalf("WriteMetaFile: %v", err)
+	}
+	checkMetaFile(t, baseDir, second)
+	if _, err := os.Stat(tmpMetaPath); !os.IsNotExist(err) {
+		t.Fatalf("the temporary index is still there: %v", err)
+	}
+
+	// a failed write leaves the old index in place
+	if err := os.MkdirAll(ConcatPath(tmpMetaPath, "blocker"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteMetaFile(first, baseDir); err == nil {
+		t.Fatal("WriteMetaFile succeeded without its temporary file")
+	}
+	checkMetaFile(t, baseDir, second)
+}
+
+func checkMetaFile(t *testing.T, baseDir string, want map[string]*FileMetaData) {
+	t.Helper()
+	fileMetaMap, err := LoadMetaFromMetaFile(baseDir)
+	if err != nil {
+		t.Fatalf("LoadMetaFromMetaFile: %v", err)
+	}
+	if len(fileMetaMap) != len(want) {
+		t.Fatalf("index.db lists %v, want %v", fileMetaMap, want)
+	}
+	for fileName, fileMetaData := range want {
+		if !proto.Equal(fileMetaMap[fileName], fileMetaData) {
+			t.Fatalf("index.db holds %v for %s, want %v", fileMetaMap[fileName], fileName, fileMetaData)
+		}
+	}
+}
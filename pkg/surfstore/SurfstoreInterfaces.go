@@ -2,6 +2,7 @@ package surfstore
 
 import (
 	context "context"
+	"io"
 
 	emptypb "google.golang.org/protobuf/types/known/emptypb"
 )
@@ -18,6 +19,12 @@ type MetaStoreInterface interface {
 
 	// Retrieve all BlockStore Addresses
 	GetBlockStoreAddrs(ctx context.Context, _ *emptypb.Empty) (*BlockStoreAddrs, error)
+
+	// Retrieve every version of a file, oldest first
+	GetFileHistory(ctx context.Context, fileName *FileName) (*FileHistory, error)
+
+	// Commit an earlier version of a file again as its next version
+	RestoreFileVersion(ctx context.Context, fileVersion *FileVersion) (*Version, error)
 }
 
 type BlockStoreInterface interface {
@@ -42,6 +49,8 @@ type ClientInterface interface {
 	UpdateFile(fileMetaData *FileMetaData, latestVersion *int32) error
 	GetBlockStoreMap(blockHashesIn []string, blockStoreMap *map[string][]string) error
 	GetBlockStoreAddrs(blockStoreAddrs *[]string) error
+	GetFileHistory(fileName string, versions *[]*FileMetaData) error
+	RestoreFileVersion(fileName string, version int32, latestVersion *int32) error
 
 	// BlockStore
 	GetBlock(blockHash string, blockStoreAddr string, block *Block) error
@@ -49,3 +58,41 @@ type ClientInterface interface {
 	MissingBlocks(blockHashesIn []string, blockStoreAddr string, blockHashesOut *[]string) error
 	GetBlockHashes(blockStoreAddr string, blockHashes *[]string) error
 }
+
+type BlockBackend interface {
+	// Get the block stored under a hash, or ErrBlockNotFound
+	Get(hash string) (*Block, error)
+
+	// Store a block under its hash
+	Put(hash string, block *Block) error
+
+	// Check whether a block is stored under a hash
+	Has(hash string) (bool, error)
+
+	// List the hashes of all stored blocks
+	Hashes() ([]string, error)
+
+	// Release any resources held by the backend
+	Close() error
+}
+
+type Chunker interface {
+	// Name of the scheme, recorded with the hash lists the chunker produces
+	Scheme() string
+
+	// Record the scheme and its parameters in a file's metadata
+	Describe(fileMetaData *FileMetaData)
+
+	// Split a file into blocks and hash them using up to workers goroutines
+	Chunk(r io.Reader, workers int) ([]HashedBlock, error)
+}
+
+type ConflictResolver interface {
+	// Name of the policy
+	Policy() string
+
+	// Decide between the local and the server's version of a file that both
+	// changed since base, the version in the local index (nil for a file
+	// new on both sides). local is a tombstone if the file was deleted.
+	Resolve(fileName string, base, local, remote *FileMetaData) Resolution
+}
@@ -2,87 +2,240 @@ package surfstore
 
 import (
 	context "context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
 	"time"
 
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	grpc "google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/status"
 	emptypb "google.golang.org/protobuf/types/known/emptypb"
 )
 
+// MissingBlocksError is returned by UpdateFile when the MetaStore refused a
+// version because BlockStores do not hold some of its blocks
+type MissingBlocksError struct {
+	Hashes []string
+}
+
+func (e *MissingBlocksError) Error() string {
+	return fmt.Sprintf("MetaStore refused update, %d blocks are missing from BlockStores", len(e.Hashes))
+}
+
 type RPCClient struct {
-	MetaStoreAddr string
-	BaseDir       string
-	BlockSize     int
+	// MetaStoreAddrs lists every MetaStore replica, MetaStoreAddr is the one
+	// currently believed to be the leader
+	MetaStoreAddrs []string
+	MetaStoreAddr  string
+	// BaseDir and BlockSize are what ClientSync syncs, other programs pass
+	// SyncOptions to Sync
+	BaseDir   string
+	BlockSize int
+
+	Options RPCOptions
+
+	// long-lived connections shared by every copy of this client
+	pool *ConnPool
+	// calls end when ctx does, see WithContext
+	ctx context.Context
 }
 
-func (surfClient *RPCClient) GetBlock(blockHash string, blockStoreAddr string, block *Block) error {
-	// connect to the server
-	conn, err := grpc.Dial(blockStoreAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
-	if err != nil {
-		return err
-	}
-	c := NewBlockStoreClient(conn)
+// RPCOptions sets the deadline of each class of RPC and how idempotent
+// calls are retried
+type RPCOptions struct {
+	// UpdateFile, RestoreFileVersion, GetBlockStoreMap and GetBlockStoreAddrs
+	MetaTimeout time.Duration
+	// GetFileInfoMap and GetFileHistory, whose replies grow with the number
+	// of files and versions
+	ListTimeout time.Duration
+	// GetBlock, PutBlock, MissingBlocks and GetBlockHashes
+	BlockTimeout time.Duration
+
+	MaxRetries  int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+}
 
-	// perform the call
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
-	defer cancel()
-	b, err := c.GetBlock(ctx, &BlockHash{Hash: blockHash})
-	if err != nil {
-		conn.Close()
-		return err
+func DefaultRPCOptions() RPCOptions {
+	return RPCOptions{
+		MetaTimeout:  DEFAULT_META_TIMEOUT,
+		ListTimeout:  DEFAULT_LIST_TIMEOUT,
+		BlockTimeout: DEFAULT_BLOCK_TIMEOUT,
+		MaxRetries:   DEFAULT_MAX_RETRIES,
+		BaseBackoff:  DEFAULT_BASE_BACKOFF,
+		MaxBackoff:   DEFAULT_MAX_BACKOFF,
 	}
-	block.BlockData = b.BlockData
-	block.BlockSize = b.BlockSize
+}
 
-	// close the connection
-	return conn.Close()
+func (surfClient *RPCClient) GetBlock(blockHash string, blockStoreAddr string, block *Block) error {
+	return surfClient.retry(func() error {
+		// get a pooled connection to the server
+		conn, err := surfClient.conn(blockStoreAddr)
+		if err != nil {
+			return err
+		}
+		c := NewBlockStoreClient(conn)
+
+		// perform the call
+		ctx, cancel := context.WithTimeout(surfClient.baseContext(), surfClient.Options.BlockTimeout)
+		defer cancel()
+		b, err := c.GetBlock(ctx, &BlockHash{Hash: blockHash})
+		if err != nil {
+			return err
+		}
+		block.BlockData = b.BlockData
+		block.BlockSize = b.BlockSize
+		return nil
+	})
 }
 
 func (surfClient *RPCClient) PutBlock(block *Block, blockStoreAddr string, succ *bool) error {
-	// connect to the server
-	conn, err := grpc.Dial(blockStoreAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
-	if err != nil {
-		return err
-	}
-	c := NewBlockStoreClient(conn)
+	return surfClient.retry(func() error {
+		// get a pooled connection to the server
+		conn, err := surfClient.conn(blockStoreAddr)
+		if err != nil {
+			return err
+		}
+		c := NewBlockStoreClient(conn)
+
+		// perform the call
+		ctx, cancel := context.WithTimeout(surfClient.baseContext(), surfClient.Options.BlockTimeout)
+		defer cancel()
+		s, err := c.PutBlock(ctx, block)
+		if err != nil {
+			return err
+		}
+		*succ = s.GetFlag()
+		return nil
+	})
+}
 
-	// perform the call
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
-	defer cancel()
-	s, err := c.PutBlock(ctx, block)
-	if err != nil {
-		conn.Close()
-		return err
-	}
-	*succ = s.GetFlag()
+func (surfClient *RPCClient) MissingBlocks(blockHashesIn []string, blockStoreAddr string, blockHashesOut *[]string) error {
+	return surfClient.retry(func() error {
+		// get a pooled connection to the server
+		conn, err := surfClient.conn(blockStoreAddr)
+		if err != nil {
+			return err
+		}
+		c := NewBlockStoreClient(conn)
+
+		// perform the call
+		ctx, cancel := context.WithTimeout(surfClient.baseContext(), surfClient.Options.BlockTimeout)
+		defer cancel()
+		out, err := c.MissingBlocks(ctx, &BlockHashes{Hashes: blockHashesIn})
+		if err != nil {
+			return err
+		}
+		*blockHashesOut = out.GetHashes()
+		return nil
+	})
+}
 
-	// close the connection
-	return conn.Close()
+func (surfClient *RPCClient) GetFileInfoMap(serverFileInfoMap *map[string]*FileMetaData) error {
+	return surfClient.retryMetaStoreCall(surfClient.Options.ListTimeout, func(ctx context.Context, c MetaStoreClient) error {
+		fMap, err := c.GetFileInfoMap(ctx, &emptypb.Empty{})
+		if err != nil {
+			return err
+		}
+		*serverFileInfoMap = fMap.GetFileInfoMap()
+		return nil
+	})
 }
 
-func (surfClient *RPCClient) MissingBlocks(blockHashesIn []string, blockStoreAddr string, blockHashesOut *[]string) error {
-	// connect to the server
-	conn, err := grpc.Dial(blockStoreAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
-	if err != nil {
-		return err
-	}
-	c := NewBlockStoreClient(conn)
+// UpdateFile is never retried: a lost reply may hide a committed version.
+// It only moves to another replica when the update was refused or not sent.
+func (surfClient *RPCClient) UpdateFile(fileMetaData *FileMetaData, latestVersion *int32) error {
+	return surfClient.metaStoreCall(surfClient.Options.MetaTimeout, false, func(ctx context.Context, c MetaStoreClient) error {
+		v, err := c.UpdateFile(ctx, fileMetaData)
+		if err != nil {
+			return err
+		}
+		*latestVersion = v.GetVersion()
+		if len(v.GetMissingBlockHashes()) > 0 {
+			return &MissingBlocksError{Hashes: v.GetMissingBlockHashes()}
+		}
+		return nil
+	})
+}
 
-	// perform the call
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
-	defer cancel()
-	out, err := c.MissingBlocks(ctx, &BlockHashes{Hashes: blockHashesIn})
-	if err != nil {
-		conn.Close()
-		return err
-	}
-	*blockHashesOut = out.GetHashes()
+func (surfClient *RPCClient) GetBlockHashes(blockStoreAddr string, blockHashes *[]string) error {
+	return surfClient.retry(func() error {
+		// get a pooled connection to the server
+		conn, err := surfClient.conn(blockStoreAddr)
+		if err != nil {
+			return err
+		}
+		c := NewBlockStoreClient(conn)
+
+		// perform the call
+		ctx, cancel := context.WithTimeout(surfClient.baseContext(), surfClient.Options.BlockTimeout)
+		defer cancel()
+		s, err := c.GetBlockHashes(ctx, &emptypb.Empty{})
+		if err != nil {
+			return err
+		}
+		*blockHashes = s.GetHashes()
+		return nil
+	})
+}
 
-	// close the connection
-	return conn.Close()
+func (surfClient *RPCClient) GetBlockStoreMap(blockHashesIn []string, blockStoreMap *map[string][]string) error {
+	return surfClient.retryMetaStoreCall(surfClient.Options.MetaTimeout, func(ctx context.Context, c MetaStoreClient) error {
+		v, err := c.GetBlockStoreMap(ctx, &BlockHashes{Hashes: blockHashesIn})
+		if err != nil {
+			return err
+		}
+		for addr, hashes := range v.GetBlockStoreMap() {
+			(*blockStoreMap)[addr] = hashes.GetHashes()
+		}
+		return nil
+	})
 }
 
-func (surfClient *RPCClient) GetFileInfoMap(serverFileInfoMap *map[string]*FileMetaData) error {
+func (surfClient *RPCClient) GetBlockStoreAddrs(blockStoreAddrs *[]string) error {
+	return surfClient.retryMetaStoreCall(surfClient.Options.MetaTimeout, func(ctx context.Context, c MetaStoreClient) error {
+		v, err := c.GetBlockStoreAddrs(ctx, &emptypb.Empty{})
+		if err != nil {
+			return err
+		}
+		*blockStoreAddrs = v.GetBlockStoreAddrs()
+		return nil
+	})
+}
+
+func (surfClient *RPCClient) GetFileHistory(fileName string, versions *[]*FileMetaData) error {
+	return surfClient.retryMetaStoreCall(surfClient.Options.ListTimeout, func(ctx context.Context, c MetaStoreClient) error {
+		history, err := c.GetFileHistory(ctx, &FileName{Filename: fileName})
+		if err != nil {
+			return err
+		}
+		*versions = history.GetVersions()
+		return nil
+	})
+}
+
+// RestoreFileVersion is never retried, for the same reason as UpdateFile
+func (surfClient *RPCClient) RestoreFileVersion(fileName string, version int32, latestVersion *int32) error {
+	return surfClient.metaStoreCall(surfClient.Options.MetaTimeout, false, func(ctx context.Context, c MetaStoreClient) error {
+		v, err := c.RestoreFileVersion(ctx, &FileVersion{Filename: fileName, Version: version})
+		if err != nil {
+			return err
+		}
+		*latestVersion = v.GetVersion()
+		if len(v.GetMissingBlockHashes()) > 0 {
+			return &MissingBlocksError{Hashes: v.GetMissingBlockHashes()}
+		}
+		return nil
+	})
+}
+
+/*
+func (surfClient *RPCClient) GetBlockStoreAddr(blockStoreAddr *string) error {
 	// connect to the server
 	conn, err := grpc.Dial(surfClient.MetaStoreAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
 	if err != nil {
@@ -93,144 +246,241 @@ func (surfClient *RPCClient) GetFileInfoMap(serverFileInfoMap *map[string]*FileM
 	// perform the call
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
 	defer cancel()
-	fMap, err := c.GetFileInfoMap(ctx, &emptypb.Empty{})
+	bAddr, err := c.GetBlockStoreAddr(ctx, &emptypb.Empty{})
 	if err != nil {
 		conn.Close()
 		return err
 	}
-	*serverFileInfoMap = fMap.GetFileInfoMap()
+	*blockStoreAddr = bAddr.GetAddr()
 
 	// close the connection
 	return conn.Close()
+}*/
+
+// metaStoreCall connects to the MetaStore and performs call. With several
+// replicas it follows the leader hint of a follower, or tries the next
+// replica when one is down, waiting for an election between rounds. A call
+// that is not idempotent is only sent again if the replica certainly did
+// not act on it: it was never sent, or the replica refused it.
+func (surfClient *RPCClient) metaStoreCall(timeout time.Duration, idempotent bool, call func(ctx context.Context, c MetaStoreClient) error) error {
+	addr := surfClient.MetaStoreAddr
+	var err error
+	for round := 0; round < META_FAILOVER_ROUNDS; round++ {
+		tried := make(map[string]bool)
+		for !tried[addr] {
+			tried[addr] = true
+			err = surfClient.metaStoreCallAt(addr, timeout, idempotent, call)
+			if err == nil {
+				surfClient.MetaStoreAddr = addr
+				return nil
+			}
+			if ctxErr := surfClient.baseContext().Err(); ctxErr != nil {
+				return ctxErr
+			}
+			leaderAddr, failover := metaStoreRedirect(err, idempotent)
+			if !failover || len(surfClient.MetaStoreAddrs) < 2 {
+				return err
+			}
+			if leaderAddr != "" && !tried[leaderAddr] {
+				addr = leaderAddr
+				continue
+			}
+			for _, next := range surfClient.MetaStoreAddrs {
+				if !tried[next] {
+					addr = next
+					break
+				}
+			}
+		}
+		if round+1 < META_FAILOVER_ROUNDS {
+			if err := sleep(surfClient.baseContext(), RAFT_ELECTION_TIMEOUT_MAX); err != nil {
+				return err
+			}
+		}
+	}
+	return err
 }
 
-func (surfClient *RPCClient) UpdateFile(fileMetaData *FileMetaData, latestVersion *int32) error {
-	// connect to the server
-	conn, err := grpc.Dial(surfClient.MetaStoreAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+func (surfClient *RPCClient) metaStoreCallAt(addr string, timeout time.Duration, idempotent bool, call func(ctx context.Context, c MetaStoreClient) error) error {
+	// get a pooled connection to the server
+	conn, err := surfClient.conn(addr)
 	if err != nil {
 		return err
 	}
 	c := NewMetaStoreClient(conn)
 
 	// perform the call
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	ctx, cancel := context.WithTimeout(surfClient.baseContext(), timeout)
 	defer cancel()
-	v, err := c.UpdateFile(ctx, fileMetaData)
-	if err != nil {
-		conn.Close()
-		return err
+	if !idempotent {
+		// once connected, a failed call may have been acted on
+		if err := waitUntilReady(ctx, conn); err != nil {
+			return err
+		}
 	}
-	*latestVersion = v.GetVersion()
-
-	// close the connection
-	return conn.Close()
+	return call(ctx, c)
 }
 
-func (surfClient *RPCClient) GetBlockHashes(blockStoreAddr string, blockHashes *[]string) error {
-	// connect to the server
-	// fmt.Printf("Get block hashes for %v\n", blockStoreAddr)
-	conn, err := grpc.Dial(blockStoreAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
-	if err != nil {
-		return err
+// errNotConnected means a call was not sent because its replica could not
+// be reached
+var errNotConnected = status.Error(codes.Unavailable, "MetaStore replica is not reachable")
+
+// waitUntilReady waits until conn is connected, failing with
+// errNotConnected if it cannot connect before ctx ends
+func waitUntilReady(ctx context.Context, conn *grpc.ClientConn) error {
+	conn.Connect()
+	for {
+		state := conn.GetState()
+		switch state {
+		case connectivity.Ready:
+			return nil
+		case connectivity.TransientFailure, connectivity.Shutdown:
+			return errNotConnected
+		}
+		if !conn.WaitForStateChange(ctx, state) {
+			return errNotConnected
+		}
 	}
-	c := NewBlockStoreClient(conn)
+}
 
-	// perform the call
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
-	defer cancel()
-	s, err := c.GetBlockHashes(ctx, &emptypb.Empty{})
-	if err != nil {
-		conn.Close()
-		return err
-	}
-	*blockHashes = s.GetHashes()
-	// close the connection
-	return conn.Close()
+// retryMetaStoreCall is metaStoreCall for idempotent calls
+func (surfClient *RPCClient) retryMetaStoreCall(timeout time.Duration, call func(ctx context.Context, c MetaStoreClient) error) error {
+	return surfClient.retry(func() error {
+		return surfClient.metaStoreCall(timeout, true, call)
+	})
 }
 
-func (surfClient *RPCClient) GetBlockStoreMap(blockHashesIn []string, blockStoreMap *map[string][]string) error {
-	// connect to the server
-	//fmt.Println("begin get block store map in rpc client")
-	conn, err := grpc.Dial(surfClient.MetaStoreAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
-	if err != nil {
-		return err
+// retry runs an idempotent call, retrying transient failures up to
+// MaxRetries times with exponential backoff and full jitter. It gives up
+// as soon as the client's context ends.
+func (surfClient *RPCClient) retry(call func() error) error {
+	ctx := surfClient.baseContext()
+	backoff := surfClient.Options.BaseBackoff
+	for attempt := 0; ; attempt++ {
+		err := call()
+		if err != nil && ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err == nil || attempt >= surfClient.Options.MaxRetries || !isTransient(err) {
+			return err
+		}
+		if backoff > 0 {
+			if err := sleep(ctx, time.Duration(rand.Int63n(int64(backoff)))); err != nil {
+				return err
+			}
+		}
+		backoff = min(2*backoff, surfClient.Options.MaxBackoff)
 	}
-	c := NewMetaStoreClient(conn)
-	// fmt.Printf("list all hashin: %v\n", blockHashesIn)
+}
 
-	// perform the call
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
-	defer cancel()
-	v, err := c.GetBlockStoreMap(ctx, &BlockHashes{Hashes: blockHashesIn})
-	if err != nil {
-		conn.Close()
-		return err
+// sleep waits for d, or returns early with the error of ctx once it ends
+func sleep(ctx context.Context, d time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
 	}
+}
 
-	for addr, hashes := range v.GetBlockStoreMap() {
-		(*blockStoreMap)[addr] = hashes.GetHashes()
+// isTransient reports whether a failed call may succeed when tried again
+func isTransient(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted:
+		return true
+	default:
+		return false
 	}
-	// fmt.Printf("list the block store map: %v\n", blockStoreMap)
-	//fmt.Println("finish get block store map in rpc client")
+}
 
-	// close the connection
-	return conn.Close()
+// metaStoreRedirect reports whether a failed MetaStore call should be sent
+// to another replica, and the leader address if the replica knew it. A
+// call that is not idempotent only moves on from a replica that refused it
+// or never received it, since a timeout or lost connection may hide a
+// committed update.
+func metaStoreRedirect(err error, idempotent bool) (string, bool) {
+	st, ok := status.FromError(err)
+	if !ok {
+		return "", false
+	}
+	switch st.Code() {
+	case codes.FailedPrecondition:
+		for _, detail := range st.Details() {
+			if info, ok := detail.(*errdetails.ErrorInfo); ok && info.GetReason() == RAFT_NOT_LEADER_REASON {
+				return info.GetMetadata()[RAFT_LEADER_ADDR_KEY], true
+			}
+		}
+		return "", false
+	case codes.Unavailable:
+		return "", idempotent || errors.Is(err, errNotConnected) || st.Message() == ErrServerCrashed.Error()
+	case codes.DeadlineExceeded:
+		return "", idempotent
+	default:
+		return "", false
+	}
 }
 
-func (surfClient *RPCClient) GetBlockStoreAddrs(blockStoreAddrs *[]string) error {
-	// connect to the server
-	conn, err := grpc.Dial(surfClient.MetaStoreAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
-	if err != nil {
-		return err
+// sharedConnPool serves clients that were not built by
+// NewSurfstoreRPCClient. It is created once, so concurrent calls on such a
+// client never race to set up a pool, and it is never closed.
+var sharedConnPool = sync.OnceValue(func() *ConnPool {
+	return NewConnPool(DEFAULT_CONN_POOL_SIZE)
+})
+
+// conn returns a pooled connection to addr
+func (surfClient *RPCClient) conn(addr string) (*grpc.ClientConn, error) {
+	if surfClient.pool == nil {
+		return sharedConnPool().Get(addr)
 	}
-	c := NewMetaStoreClient(conn)
+	return surfClient.pool.Get(addr)
+}
 
-	// perform the call
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
-	defer cancel()
-	v, err := c.GetBlockStoreAddrs(ctx, &emptypb.Empty{})
-	if err != nil {
-		conn.Close()
-		return err
+// Close releases the client's connections, a client without its own pool
+// has none to release
+func (surfClient *RPCClient) Close() error {
+	if surfClient.pool == nil {
+		return nil
 	}
-	*blockStoreAddrs = v.GetBlockStoreAddrs()
+	return surfClient.pool.Close()
+}
 
-	// close the connection
-	return conn.Close()
+// WithContext returns a copy of the client whose calls, retries and
+// failovers end when ctx does. The copy shares the client's connections.
+func (surfClient *RPCClient) WithContext(ctx context.Context) *RPCClient {
+	c := *surfClient
+	c.ctx = ctx
+	return &c
 }
 
-/*
-func (surfClient *RPCClient) GetBlockStoreAddr(blockStoreAddr *string) error {
-	// connect to the server
-	conn, err := grpc.Dial(surfClient.MetaStoreAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
-	if err != nil {
-		return err
+func (surfClient *RPCClient) baseContext() context.Context {
+	if surfClient.ctx == nil {
+		return context.Background()
 	}
-	c := NewMetaStoreClient(conn)
+	return surfClient.ctx
+}
 
-	// perform the call
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
-	defer cancel()
-	bAddr, err := c.GetBlockStoreAddr(ctx, &emptypb.Empty{})
-	if err != nil {
-		conn.Close()
-		return err
+// withContext binds the calls of an RPCClient to ctx, other clients are
+// returned as they are
+func withContext(ctx context.Context, client ClientInterface) ClientInterface {
+	if rpcClient, ok := client.(*RPCClient); ok {
+		return rpcClient.WithContext(ctx)
 	}
-	*blockStoreAddr = bAddr.GetAddr()
-
-	// close the connection
-	return conn.Close()
-}*/
+	return client
+}
 
 // This line guarantees all method for RPCClient are implemented
 var _ ClientInterface = new(RPCClient)
 
-// Create an Surfstore RPC client
+// Create an Surfstore RPC client. hostPort may list several comma-separated
+// MetaStore replicas.
 func NewSurfstoreRPCClient(hostPort, baseDir string, blockSize int) RPCClient {
-
+	metaStoreAddrs := strings.Split(hostPort, CONFIG_DELIMITER)
 	return RPCClient{
-		MetaStoreAddr: hostPort,
-		BaseDir:       baseDir,
-		BlockSize:     blockSize,
+		MetaStoreAddrs: metaStoreAddrs,
+		MetaStoreAddr:  metaStoreAddrs[0],
+		BaseDir:        baseDir,
+		BlockSize:      blockSize,
+		Options:        DefaultRPCOptions(),
+		pool:           NewConnPool(DEFAULT_CONN_POOL_SIZE),
 	}
 }
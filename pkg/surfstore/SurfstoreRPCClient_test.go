@@ -0,0 +1,215 @@
+package surfstore
+
+import (
+	context "context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	grpc "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
+)
+
+// fakeMetaStore answers UpdateFile and GetFileInfoMap with its handlers and
+// counts the calls it received
+type fakeMetaStore struct {
+	UnimplementedMetaStoreServer
+
+	updateFile     func(ctx context.Context) (*Version, error)
+	getFileInfoMap func(ctx context.Context) (*FileInfoMap, error)
+	calls          atomic.Int32
+}
+
+func (f *fakeMetaStore) UpdateFile(ctx context.Context, fileMetaData *FileMetaData) (*Version, error) {
+	f.calls.Add(1)
+	return f.updateFile(ctx)
+}
+
+func (f *fakeMetaStore) GetFileInfoMap(ctx context.Context, _ *emptypb.Empty) (*FileInfoMap, error) {
+	f.calls.Add(1)
+	return f.getFileInfoMap(ctx)
+}
+
+func startFakeMetaStore(t *testing.T, f *fakeMetaStore) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	RegisterMetaStoreServer(server, f)
+	go server.Serve(ln)
+	t.Cleanup(server.Stop)
+	return ln.Addr().String()
+}
+
+// closedAddr is an address nothing listens on
+func closedAddr(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	return addr
+}
+
+func testFailoverClient(t *testing.T, addrs ...string) *RPCClient {
+	client := NewSurfstoreRPCClient(addrs[0], "", 4096)
+	client.MetaStoreAddrs = addrs
+	client.Options.MetaTimeout = 200 * time.Millisecond
+	client.Options.ListTimeout = 200 * time.Millisecond
+	client.Options.MaxRetries = 0
+	t.Cleanup(func() { client.Close() })
+	return &client
+}
+
+// hang answers once the caller's deadline has passed, like a leader that
+// appended an update but could not reach a majority in time
+func hang(ctx context.Context) error {
+	<-ctx.Done()
+	return status.Error(codes.DeadlineExceeded, ctx.Err().Error())
+}
+
+func committed(ctx context.Context) (*Version, error) {
+	return &Version{Version: 1}, nil
+}
+
+func TestUpdateFileIsNotResentAfterTimeout(t *testing.T) {
+	first := &fakeMetaStore{updateFile: func(ctx context.Context) (*Version, error) { return nil, hang(ctx) }}
+	second := &fakeMetaStore{updateFile: committed}
+	client := testFailoverClient(t, startFakeMetaStore(t, first), startFakeMetaStore(t, second))
+
+	var latestVersion int32
+	err := client.UpdateFile(&FileMetaData{Filename: "a", Version: 1}, &latestVersion)
+	if status.Code(err) != codes.DeadlineExceeded {
+		t.Fatalf("UpdateFile: got %v, want DeadlineExceeded", err)
+	}
+	if first.calls.Load() != 1 || second.calls.Load() != 0 {
+		t.Fatalf("UpdateFile was sent %d and %d times, want 1 and 0", first.calls.Load(), second.calls.Load())
+	}
+}
+
+func TestUpdateFileIsNotResentAfterCommitUnknown(t *testing.T) {
+	first := &fakeMetaStore{updateFile: func(ctx context.Context) (*Version, error) {
+		return nil, commitUnknownError()
+	}}
+	second := &fakeMetaStore{updateFile: committed}
+	client := testFailoverClient(t, startFakeMetaStore(t, first), startFakeMetaStore(t, second))
+
+	var latestVersion int32
+	if err := client.UpdateFile(&FileMetaData{Filename: "a", Version: 1}, &latestVersion); status.Code(err) != codes.Aborted {
+		t.Fatalf("UpdateFile: got %v, want Aborted", err)
+	}
+	if second.calls.Load() != 0 {
+		t.Fatalf("UpdateFile was resent to another replica")
+	}
+}
+
+func TestUpdateFileFollowsNotLeaderRedirect(t *testing.T) {
+	leader := &fakeMetaStore{updateFile: committed}
+	leaderAddr := startFakeMetaStore(t, leader)
+	follower := &fakeMetaStore{updateFile: func(ctx context.Context) (*Version, error) {
+		st, _ := status.New(codes.FailedPrecondition, ErrNotLeader.Error()).WithDetails(&errdetails.ErrorInfo{
+			Reason:   RAFT_NOT_LEADER_REASON,
+			Metadata: map[string]string{RAFT_LEADER_ADDR_KEY: leaderAddr},
+		})
+		return nil, st.Err()
+	}}
+	client := testFailoverClient(t, startFakeMetaStore(t, follower), closedAddr(t), leaderAddr)
+
+	var latestVersion int32
+	if err := client.UpdateFile(&FileMetaData{Filename: "a", Version: 1}, &latestVersion); err != nil {
+		t.Fatalf("UpdateFile: %v", err)
+	}
+	if latestVersion != 1 || leader.calls.Load() != 1 || client.MetaStoreAddr != leaderAddr {
+		t.Fatalf("UpdateFile reached the leader %d times, version %d, now at %s", leader.calls.Load(), latestVersion, client.MetaStoreAddr)
+	}
+}
+
+func TestUpdateFileFailsOverWhenReplicaIsDown(t *testing.T) {
+	for name, refuse := range map[string]func(t *testing.T) string{
+		"unreachable": closedAddr,
+		"crashed": func(t *testing.T) string {
+			return startFakeMetaStore(t, &fakeMetaStore{updateFile: func(ctx context.Context) (*Version, error) {
+				return nil, crashedError()
+			}})
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			second := &fakeMetaStore{updateFile: committed}
+			client := testFailoverClient(t, refuse(t), startFakeMetaStore(t, second))
+
+			var latestVersion int32
+			if err := client.UpdateFile(&FileMetaData{Filename: "a", Version: 1}, &latestVersion); err != nil {
+				t.Fatalf("UpdateFile: %v", err)
+			}
+			if second.calls.Load() != 1 {
+				t.Fatalf("UpdateFile reached the second replica %d times, want 1", second.calls.Load())
+			}
+		})
+	}
+}
+
+func TestGetFileInfoMapFailsOverAfterTimeout(t *testing.T) {
+	first := &fakeMetaStore{getFileInfoMap: func(ctx context.Context) (*FileInfoMap, error) { return nil, hang(ctx) }}
+	second := &fakeMetaStore{getFileInfoMap: func(ctx context.Context) (*FileInfoMap, error) {
+		return &FileInfoMap{FileInfoMap: map[string]*FileMetaData{"a": {Filename: "a", Version: 1}}}, nil
+	}}
+	client := testFailoverClient(t, startFakeMetaStore(t, first), startFakeMetaStore(t, second))
+
+	var fileInfoMap map[string]*FileMetaData
+	if err := client.GetFileInfoMap(&fileInfoMap); err != nil {
+		t.Fatalf("GetFileInfoMap: %v", err)
+	}
+	if fileInfoMap["a"].GetVersion() != 1 || second.calls.Load() != 1 {
+		t.Fatalf("GetFileInfoMap did not fail over: got %v", fileInfoMap)
+	}
+}
+
+func TestRetryEndsWithContext(t *testing.T) {
+	for name, getFileInfoMap := range map[string]func(ctx context.Context) (*FileInfoMap, error){
+		"during a call": func(ctx context.Context) (*FileInfoMap, error) { return nil, hang(ctx) },
+		"during the backoff": func(ctx context.Context) (*FileInfoMap, error) {
+			return nil, status.Error(codes.Unavailable, "try again")
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			server := &fakeMetaStore{getFileInfoMap: getFileInfoMap}
+			client := testFailoverClient(t, startFakeMetaStore(t, server))
+			client.Options.ListTimeout = time.Minute
+			client.Options.MaxRetries = 100
+			client.Options.BaseBackoff = time.Minute
+			client.Options.MaxBackoff = time.Minute
+
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(100*time.Millisecond, cancel)
+			start := time.Now()
+			var fileInfoMap map[string]*FileMetaData
+			err := client.WithContext(ctx).GetFileInfoMap(&fileInfoMap)
+			if !errors.Is(err, context.Canceled) {
+				t.Fatalf("GetFileInfoMap: got %v, want context.Canceled", err)
+			}
+			if elapsed := time.Since(start); elapsed > 10*time.Second {
+				t.Fatalf("GetFileInfoMap returned %v after the context ended", elapsed)
+			}
+		})
+	}
+}
+
+func TestClientSyncReturnsError(t *testing.T) {
+	client := NewSurfstoreRPCClient(closedAddr(t), t.TempDir(), 4)
+	defer client.Close()
+	client.Options.ListTimeout = 200 * time.Millisecond
+	client.Options.MaxRetries = 0
+	var syncErr *SyncError
+	if err := ClientSync(client); !errors.As(err, &syncErr) {
+		t.Fatalf("ClientSync without a server: got %v, want a *SyncError", err)
+	}
+}
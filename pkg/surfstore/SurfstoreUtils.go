@@ -1,239 +1,141 @@
 package surfstore
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
 )
 
-// Implement the logic for a client syncing with the server here.
-func ClientSync(client RPCClient) {
-	baseDir := client.BaseDir
-	blockSize := client.BlockSize
-	fmt.Printf("block size is:%v\n", client.BlockSize)
-
-	// files, err := ioutil.ReadDir(baseDir)
-	// if err != nil {
-	// 	fmt.Println("Error reading directory:", err)
-	// 	return
-	// }
-
-	// // Print directory and file information
-	// for _, file := range files {
-	// 	name := file.Name()
-	// 	size := file.Size()
-	// 	modTime := file.ModTime()
-	// 	mode := file.Mode()
-
-	// 	fmt.Printf("%s\t%v\t%d bytes\t%s\n", mode, modTime, size, name)
-	// }
-
-	fmt.Println("step1")
-	// step1: fetch local file info
-	localMetaMap := make(map[string][]string)
-	localBlockMap := make(map[string][]*Block)
-	localHashBlockMap := make(map[string]map[string]*Block)
-	// fmt.Printf("%v\n", baseDir)
-	err := filepath.Walk(baseDir, func(path string, info os.FileInfo, err error) error {
-		// fmt.Printf("walk once: %v\n", info.Name())
-		if err != nil {
-			return err
-		}
-		if info.IsDir() {
-			if path == baseDir {
-				return nil
-			} else {
-				return fmt.Errorf("error containing directory")
-			}
+// ClientSync syncs the client's base directory in blockSize blocks with the
+// default settings, see Sync. Skipped files are listed on stderr and the
+// block statistics printed once the sync succeeded.
+func ClientSync(client RPCClient) error {
+	opts := DefaultSyncOptions(client.BaseDir, client.BlockSize)
+	report, err := Sync(context.Background(), &client, opts)
+	for _, fileAction := range report.Files {
+		if fileAction.Action == ActionSkip {
+			fmt.Fprintf(os.Stderr, "Skipping file: %v\n", fileAction.Err)
 		}
-		fileName := info.Name()
-		if fileName == "index.db" {
-			return nil
-		}
-		//file, err := os.Open(path)
-		hashList := []string{}
-		blockList := []*Block{}
-		// if err != nil {
-		// 	return err
-		// }
-		buf, err := os.ReadFile(path)
-		if err != nil {
-			return err
-		}
-		totalBytesRead := len(buf)
-		// bytesRead, err := ReadBlock(file, buf)
-		// if bytesRead == 0 {
-		// 	break
-		// }
-		bytesRead := 0
-		for {
-			if totalBytesRead-bytesRead > blockSize {
-				blockData := buf[bytesRead : bytesRead+blockSize]
-				hashList = append(hashList, GetBlockHashString(blockData))
-				block := &Block{
-					BlockData: blockData,
-					BlockSize: int32(len(blockData)),
-				}
-				blockList = append(blockList, block)
-				bytesRead += blockSize
-			} else {
-				blockData := buf[bytesRead:]
-				if len(blockData) == 0 {
-					hashList = append(hashList, "-1")
-				} else {
-					hashList = append(hashList, GetBlockHashString(blockData))
-				}
-				block := &Block{
-					BlockData: blockData,
-					BlockSize: int32(len(blockData)),
-				}
-				blockList = append(blockList, block)
-				break
-			}
-		}
-		// if err == io.EOF || bytesRead == 0 {
-		// 	break
-		// }
-		localMetaMap[fileName] = hashList
-		localBlockMap[fileName] = blockList
-		localHashBlockMap[fileName] = make(map[string]*Block)
-		for i := range localMetaMap[fileName] {
-			localHashBlockMap[fileName][hashList[i]] = blockList[i]
-		}
-		return nil
-	})
+	}
 	if err != nil {
-		log.Fatalf("Error fetching local file info: %v\n", err)
-	}
-	// fmt.Printf("%v\n", localMetaMap)
-	//fmt.Printf("%v\n", localBlockMap)
-	fmt.Println("step2")
-	// step2: fetch local index.db map
-	localIndexMap := make(map[string]*FileMetaData)
-	if _, err := os.Stat(baseDir + "/" + "index.db"); os.IsNotExist(err) {
-		// no index.db
-	} else {
-		// index.db exists
-		localMap, err := LoadMetaFromMetaFile(baseDir)
-		if err != nil {
-			log.Fatalf("Error Reading index.db: %v\n", err)
-		}
-		for k, v := range localMap {
-			localIndexMap[k] = v
-		}
+		return err
 	}
-	// PrintMetaMap(localIndexMap)
-	fmt.Println("step3")
-	// step3: fetch and push all local changes to cloud
-	blockStoreMap := make(map[string][]string)
-	// check newly created or modified file
-	for fileName, hashList := range localMetaMap {
-		err := client.GetBlockStoreMap(hashList, &blockStoreMap)
-		if err != nil {
-			log.Fatalf("Error Getting block store map: %v\n", err)
+	fmt.Println(report.Stats)
+	return nil
+}
+
+// pullOrder lists the files of a remote index so that parent directories
+// are created before their children and deleted after them
+func pullOrder(remoteIndexMap map[string]*FileMetaData) []string {
+	fileNames := make([]string, 0, len(remoteIndexMap))
+	for fileName := range remoteIndexMap {
+		fileNames = append(fileNames, fileName)
+	}
+	deleted := func(fileName string) bool {
+		return IsTombstone(remoteIndexMap[fileName])
+	}
+	sort.Slice(fileNames, func(i, j int) bool {
+		if deleted(fileNames[i]) != deleted(fileNames[j]) {
+			return !deleted(fileNames[i])
 		}
-		if fileMetaData, ok := localIndexMap[fileName]; !ok {
-			// new created local file
-			fileMetaData := &FileMetaData{
-				Filename:      fileName,
-				Version:       1,
-				BlockHashList: hashList,
-			}
-			// fmt.Printf("Create a new file to cloud\n")
-			err = Push(&client, fileMetaData, localHashBlockMap[fileName], blockStoreMap)
-			if err != nil {
-				log.Fatalf("Error Creating new File on Cloud: %v\n", err)
-			}
-		} else {
-			// both contain the file, then compare the hash list
-			if !CompareHashLists(hashList, fileMetaData.GetBlockHashList()) {
-				// if not equal, we need to push it to cloud
-				newFileMetaData := &FileMetaData{
-					Filename:      fileName,
-					Version:       fileMetaData.Version + 1,
-					BlockHashList: hashList,
-				}
-				err := Push(&client, newFileMetaData, localHashBlockMap[fileName], blockStoreMap)
-				if err != nil {
-					log.Fatalf("Error Writing File on Cloud: %v\n", err)
-				}
+		if deleted(fileNames[i]) {
+			if di, dj := strings.Count(fileNames[i], "/"), strings.Count(fileNames[j], "/"); di != dj {
+				return di > dj
 			}
 		}
-	}
-	fmt.Println("step3.5")
-	// check deleted file
-	for fileName, fileMetaData := range localIndexMap {
-		if _, ok := localMetaMap[fileName]; !ok && fileMetaData.GetBlockHashList()[0] != "0" {
-			// file in index.db, but not in directory
-			newFileMetaData := &FileMetaData{
-				Filename:      fileName,
-				Version:       fileMetaData.Version + 1,
-				BlockHashList: []string{"0"},
-			}
-			err := Push(&client, newFileMetaData, localHashBlockMap[fileName], make(map[string][]string))
-			if err != nil {
-				log.Fatalf("Error deleting File on cloud: %v\n", err)
-			}
+		return fileNames[i] < fileNames[j]
+	})
+	return fileNames
+}
 
-		}
+// chunkLocalFile streams a file through the chunker and records where each
+// block lies. Only hashes and block locations are kept, blocks are read
+// again when they are uploaded. It returns the hash list and the file size.
+func chunkLocalFile(path string, chunker Chunker, localBlocks LocalBlockIndex) ([]string, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
 	}
-
-	fmt.Println("step4")
-	// step4: fetch remote index.db map
-	remoteIndexMap := make(map[string]*FileMetaData)
-	err = client.GetFileInfoMap(&remoteIndexMap)
+	defer file.Close()
+	blocks, err := chunker.Chunk(file, runtime.GOMAXPROCS(0))
 	if err != nil {
-		log.Fatalf("Error Fetching Remote index.db: %v\n", err)
+		return nil, 0, err
+	}
+	hashList := []string{}
+	var size int64
+	for _, block := range blocks {
+		hashList = append(hashList, block.Hash)
+		localBlocks.Add(block.Hash, path, block.Offset, block.Size)
+		size += int64(block.Size)
 	}
-	// PrintMetaMap(remoteIndexMap)
+	if len(hashList) == 0 {
+		// clients that predate the size field need the marker
+		hashList = append(hashList, EMPTYFILE_HASHVALUE)
+	}
+	return hashList, size, nil
+}
 
-	fmt.Println("step5")
-	// step5: pull remote changes to local
-	// check newly created or modified file on cloud
-	for fileName, fileMetaData := range remoteIndexMap {
-		err := client.GetBlockStoreMap(fileMetaData.GetBlockHashList(), &blockStoreMap)
-		if err != nil {
-			log.Fatalf("Error Getting block store map: %v\n", err)
-		}
-		if hashList, ok := localMetaMap[fileName]; !ok {
-			// no such file on local
-			// fmt.Printf("create new file on local\n")
-			err := Pull(&client, fileMetaData, baseDir, blockStoreMap)
-			if err != nil {
-				log.Fatalf("Error Write local file: %v\n", err)
+// Pull writes the remote version of a file into baseDir. Blocks found in
+// local files are copied from disk, only the rest is fetched from the
+// BlockStores. The file is assembled next to its destination and renamed
+// over it, so blocks of the old version stay readable until the end.
+func Pull(ctx context.Context, client ClientInterface, opts SyncOptions, fileMetaData *FileMetaData, blockStoreMap map[string][]string, localBlocks LocalBlockIndex, stats *SyncStats) error {
+	baseDir := opts.BaseDir
+	fileName := fileMetaData.GetFilename()
+	if err := ValidateFilename(fileName); err != nil {
+		return err
+	}
+	if err := checkSymlinkParents(baseDir, fileName); err != nil {
+		return err
+	}
+	filePath := ConcatPath(baseDir, fileName)
+	if IsTombstone(fileMetaData) {
+		// need to delete local file
+		err := os.Remove(filePath)
+		if err != nil && !os.IsNotExist(err) {
+			// a directory holding files nobody synced yet stays, the
+			// next sync uploads it again
+			if entries, readErr := os.ReadDir(filePath); fileMetaData.GetDirectory() && readErr == nil && len(entries) > 0 {
+				return nil
 			}
-		} else {
-			if !CompareHashLists(hashList, fileMetaData.GetBlockHashList()) {
-				// local version is out-dated
-				err := Pull(&client, fileMetaData, baseDir, blockStoreMap)
-				if err != nil {
-					log.Fatalf("Error Update local file: %v\n", err)
-				}
+			return err
+		}
+		return nil
+	}
+	if fileMetaData.GetDirectory() {
+		if info, err := os.Lstat(filePath); err == nil && !info.IsDir() {
+			if err := os.Remove(filePath); err != nil {
+				return err
 			}
 		}
+		if err := os.MkdirAll(filePath, 0755); err != nil {
+			return err
+		}
+		return applyAttributes(filePath, fileMetaData, opts.Xattrs)
 	}
-
-	fmt.Println("step6")
-	// step6: sync local index.db
-	err = WriteMetaFile(remoteIndexMap, baseDir)
-	if err != nil {
-		log.Fatalf("Error Update local index.db: %v\n", err)
+	if target := fileMetaData.GetSymlinkTarget(); target != "" {
+		if opts.SafeLinks && SymlinkEscapes(fileName, target) {
+			return fmt.Errorf("%w: %q points to %q outside the base directory", ErrUnsafeSymlink, fileName, target)
+		}
+		return createSymlink(filePath, target)
 	}
-}
 
-func Pull(client *RPCClient, fileMetaData *FileMetaData, baseDir string, blockStoreMap map[string][]string) error {
-	block := Block{}
-	fileName := fileMetaData.GetFilename()
 	// fmt.Printf("file path: %v\n", baseDir+"/"+fileName)
-	file, err := os.OpenFile(baseDir+"/"+fileName, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
-	if err != nil {
+	parentDir := filepath.Dir(filePath)
+	if err := os.MkdirAll(parentDir, 0755); err != nil {
 		return err
 	}
-	_, err = file.Seek(0, 0)
+	file, err := os.CreateTemp(parentDir, TEMPFILE_PREFIX+"*")
 	if err != nil {
 		return err
 	}
+	defer os.Remove(file.Name())
 	defer file.Close()
 	reverseBlockStoreMap := make(map[string]string)
 	for addr, hashList := range blockStoreMap {
@@ -242,52 +144,292 @@ func Pull(client *RPCClient, fileMetaData *FileMetaData, baseDir string, blockSt
 		}
 	}
 
-	for _, hash := range fileMetaData.GetBlockHashList() {
-		if hash == "0" {
-			// need to delete local file
-			err := os.Remove(baseDir + "/" + fileName)
-			if err != nil {
+	hashList := FileBlockHashes(fileMetaData)
+	var written int64
+	// blocks are fetched concurrently a window at a time and written in
+	// order, so memory stays bounded however large the file is
+	window := max(opts.Workers, 1) * PULL_WINDOW_PER_WORKER
+	for start := 0; start < len(hashList); start += window {
+		batch := hashList[start:min(start+window, len(hashList))]
+		blocks := make([][]byte, len(batch))
+		err := transferBlocks(ctx, opts.Workers, len(batch), func(i int) error {
+			hash := batch[i]
+			if blockData, ok := localBlocks.Read(hash); ok {
+				blocks[i] = blockData
+				stats.count(&stats.BlocksReused, &stats.BytesReused, 1, int64(len(blockData)))
+				return nil
+			}
+			block := Block{}
+			if err := client.GetBlock(hash, reverseBlockStoreMap[hash], &block); err != nil {
 				return err
 			}
+			blocks[i] = block.GetBlockData()
+			stats.count(&stats.BlocksDownloaded, &stats.BytesDownloaded, 1, int64(len(blocks[i])))
 			return nil
-		}
-		if hash == "-1" {
-			return nil
-		}
-		err := client.GetBlock(hash, reverseBlockStoreMap[hash], &block)
+		})
 		if err != nil {
 			return err
 		}
-		//fmt.Printf("Block data: %v\n", block.GetBlockData())
-		err = WriteBlock(file, block.GetBlockData(), int(block.GetBlockSize()))
-		if err != nil {
-			return err
+		for _, blockData := range blocks {
+			err = WriteBlock(file, blockData, len(blockData))
+			if err != nil {
+				return err
+			}
+			written += int64(len(blockData))
 		}
 	}
-	return nil
+	// versions written before sizes were recorded have size 0
+	if size := fileMetaData.GetSize(); size > 0 && written != size {
+		return fmt.Errorf("pulled %d bytes of %q, its version records %d", written, fileName, size)
+	}
+	if err := file.Chmod(0644); err != nil {
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	// the recorded mode replaces the default, and the mtime is set last so
+	// the writes above do not change it
+	if err := applyAttributes(file.Name(), fileMetaData, opts.Xattrs); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), filePath)
+}
+
+// BlockLocation is where a block can be read from a local file
+type BlockLocation struct {
+	Path   string
+	Offset int64
+	Size   int
+}
+
+// LocalBlockIndex maps block hashes to a local copy of the block, so pulls
+// can reuse bytes already on disk instead of downloading them
+type LocalBlockIndex map[string]BlockLocation
+
+func (index LocalBlockIndex) Add(hash string, path string, offset int64, size int) {
+	if _, ok := index[hash]; !ok {
+		index[hash] = BlockLocation{Path: path, Offset: offset, Size: size}
+	}
+}
+
+// Move points the blocks of a renamed file at its new path
+func (index LocalBlockIndex) Move(oldPath string, newPath string) {
+	for hash, location := range index {
+		if location.Path == oldPath {
+			location.Path = newPath
+			index[hash] = location
+		}
+	}
+}
+
+// Read returns the block's bytes if the local copy still holds them. Files
+// change during a sync, so the bytes are checked against the hash.
+func (index LocalBlockIndex) Read(hash string) ([]byte, bool) {
+	location, ok := index[hash]
+	if !ok {
+		return nil, false
+	}
+	file, err := os.Open(location.Path)
+	if err != nil {
+		return nil, false
+	}
+	defer file.Close()
+	blockData := make([]byte, location.Size)
+	if _, err := file.ReadAt(blockData, location.Offset); err != nil {
+		return nil, false
+	}
+	if GetBlockHashString(blockData) != hash {
+		return nil, false
+	}
+	return blockData, true
+}
+
+// SyncStats counts the block traffic of one sync. Transfers run
+// concurrently, so counters are updated through count.
+type SyncStats struct {
+	mu sync.Mutex
+
+	BlocksUploaded int
+	BytesUploaded  int64
+	// blocks that did not need to be sent because the BlockStore already
+	// had them, from another file, an earlier version or earlier in this file
+	BlocksDeduplicated int
+	BytesDeduplicated  int64
+	BlocksDownloaded   int
+	BytesDownloaded    int64
+	// blocks of pulled files that were copied from local files instead
+	BlocksReused int
+	BytesReused  int64
+
+	Conflicts []Conflict
+}
+
+// count adds n blocks of size bytes each to a pair of counters
+func (stats *SyncStats) count(blocks *int, bytes *int64, n int, size int64) {
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+	*blocks += n
+	*bytes += int64(n) * size
+}
+
+func (stats *SyncStats) addConflict(fileName string, conflictCopy string, resolution Resolution) {
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+	stats.Conflicts = append(stats.Conflicts, Conflict{FileName: fileName, ConflictCopy: conflictCopy, Resolution: resolution})
+}
+
+func (stats *SyncStats) addMerge(fileName string, overlaps int) {
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+	stats.Conflicts = append(stats.Conflicts, Conflict{FileName: fileName, Merged: true, Overlaps: overlaps})
+}
+
+func (stats *SyncStats) String() string {
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+	summary := fmt.Sprintf("uploaded %d blocks (%d bytes), deduplication saved %d blocks (%d bytes), "+
+		"downloaded %d blocks (%d bytes), reused %d local blocks (%d bytes)",
+		stats.BlocksUploaded, stats.BytesUploaded, stats.BlocksDeduplicated, stats.BytesDeduplicated,
+		stats.BlocksDownloaded, stats.BytesDownloaded, stats.BlocksReused, stats.BytesReused)
+	if len(stats.Conflicts) > 0 {
+		summary += fmt.Sprintf(", %d conflicts", len(stats.Conflicts))
+	}
+	for _, conflict := range stats.Conflicts {
+		summary += fmt.Sprintf("\nconflict: %q was changed locally and on the server, ", conflict.FileName)
+		switch {
+		case conflict.Merged && conflict.Overlaps > 0:
+			summary += fmt.Sprintf("merged both versions, %d overlapping changes are marked", conflict.Overlaps)
+		case conflict.Merged:
+			summary += "merged both versions"
+		case conflict.Resolution == KeepLocal:
+			summary += "kept the local version"
+		case conflict.Resolution == KeepBoth:
+			summary += fmt.Sprintf("kept the server version and the local version as %q", conflict.ConflictCopy)
+		default:
+			summary += "kept the server version"
+		}
+	}
+	return summary
+}
+
+// Push uploads the blocks of a file before committing its new version, so
+// no client ever sees a version whose blocks are not stored yet. Blocks are
+// read from the local files listed in localBlocks as they are sent. If
+// another client committed the version first, ErrVersionConflict is returned.
+func Push(ctx context.Context, client ClientInterface, opts SyncOptions, fileMetaData *FileMetaData, localBlocks LocalBlockIndex, blockStoreMap map[string][]string, stats *SyncStats) error {
+	err := putBlocks(ctx, client, opts.Workers, localBlocks, blockStoreMap, nil, stats)
+	if err != nil {
+		return err
+	}
+	return updateFileVersion(ctx, client, opts, fileMetaData, localBlocks, blockStoreMap, stats)
 }
 
-func Push(client *RPCClient, fileMetaData *FileMetaData, hashBlockMap map[string]*Block, blockStoreMap map[string][]string) error {
+// updateFileVersion commits a version whose blocks should already be stored,
+// sending only the blocks the MetaStore reports missing. A version that
+// only changes attributes is committed with it directly, since its blocks
+// were stored with an earlier version.
+func updateFileVersion(ctx context.Context, client ClientInterface, opts SyncOptions, fileMetaData *FileMetaData, localBlocks LocalBlockIndex, blockStoreMap map[string][]string, stats *SyncStats) error {
 	var version int32
 	err := client.UpdateFile(fileMetaData, &version)
+	var missingErr *MissingBlocksError
+	if errors.As(err, &missingErr) {
+		// a BlockStore lost blocks since the upload, send them once more
+		missing := make(map[string]bool)
+		for _, hash := range missingErr.Hashes {
+			missing[hash] = true
+		}
+		err = putBlocks(ctx, client, opts.Workers, localBlocks, blockStoreMap, missing, stats)
+		if err != nil {
+			return err
+		}
+		err = client.UpdateFile(fileMetaData, &version)
+	}
 	if err != nil {
 		return err
 	}
-	//
-	// fmt.Printf("version: %v\n", int(version))
 	if int(version) == -1 {
-		return nil
+		return fmt.Errorf("%w: %q has a newer version than %d on the server", ErrVersionConflict,
+			fileMetaData.GetFilename(), fileMetaData.GetVersion()-1)
+	}
+	return nil
+}
+
+// putBlocks uploads the blocks of a file that their BlockStores do not hold
+// yet. A non-nil only skips that check and sends exactly those hashes. The
+// BlockStores are asked concurrently, then all uploads share one worker pool,
+// so at most one block per worker is held in memory.
+func putBlocks(ctx context.Context, client ClientInterface, workers int, localBlocks LocalBlockIndex, blockStoreMap map[string][]string, only map[string]bool, stats *SyncStats) error {
+	type blockStoreHashes struct {
+		addr        string
+		hashes      []string
+		occurrences map[string]int
+		missing     []string
 	}
-	var succ bool
+	stores := []*blockStoreHashes{}
 	for addr, hashList := range blockStoreMap {
+		store := &blockStoreHashes{addr: addr, occurrences: make(map[string]int)}
 		for _, hash := range hashList {
-			err := client.PutBlock(hashBlockMap[hash], addr, &succ)
-			if err != nil {
-				return err
+			if only != nil && !only[hash] {
+				continue
+			}
+			if _, ok := localBlocks[hash]; !ok {
+				continue
+			}
+			if store.occurrences[hash] == 0 {
+				store.hashes = append(store.hashes, hash)
 			}
+			store.occurrences[hash]++
+		}
+		if len(store.hashes) > 0 {
+			store.missing = store.hashes
+			stores = append(stores, store)
 		}
 	}
-	return nil
+
+	if only == nil {
+		err := transferBlocks(ctx, workers, len(stores), func(i int) error {
+			return client.MissingBlocks(stores[i].hashes, stores[i].addr, &stores[i].missing)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	type blockUpload struct {
+		addr string
+		hash string
+	}
+	uploads := []blockUpload{}
+	for _, store := range stores {
+		missing := make(map[string]bool)
+		for _, hash := range store.missing {
+			missing[hash] = true
+		}
+		for _, hash := range store.hashes {
+			skipped := store.occurrences[hash]
+			if missing[hash] {
+				uploads = append(uploads, blockUpload{addr: store.addr, hash: hash})
+				skipped--
+			}
+			stats.count(&stats.BlocksDeduplicated, &stats.BytesDeduplicated, skipped, int64(localBlocks[hash].Size))
+		}
+	}
+
+	return transferBlocks(ctx, workers, len(uploads), func(i int) error {
+		blockData, ok := localBlocks.Read(uploads[i].hash)
+		if !ok {
+			return fmt.Errorf("block %s changed on disk during the sync", uploads[i].hash)
+		}
+		block := &Block{BlockData: blockData, BlockSize: int32(len(blockData))}
+		var succ bool
+		err := client.PutBlock(block, uploads[i].addr, &succ)
+		if err != nil {
+			return err
+		}
+		stats.count(&stats.BlocksUploaded, &stats.BytesUploaded, 1, int64(len(blockData)))
+		return nil
+	})
 }
 
 func ReadBlock(file *os.File, buf []byte) (int, error) {
@@ -0,0 +1,73 @@
+package surfstore
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+var ErrUnsafeSymlink = fmt.Errorf("unsafe symlink")
+
+func isSymlink(info os.FileInfo) bool {
+	return info.Mode()&os.ModeSymlink != 0
+}
+
+// SymlinkEscapes reports whether the link fileName, pointing to target,
+// leads outside the sync root. Absolute targets always count as outside.
+// The check is lexical, which is enough when every link is checked: a link
+// that stays inside cannot make another one leave.
+func SymlinkEscapes(fileName string, target string) bool {
+	if target == "" || filepath.IsAbs(target) || strings.HasPrefix(target, "/") {
+		return true
+	}
+	resolved := path.Join(path.Dir(fileName), filepath.ToSlash(target))
+	return resolved == ".." || strings.HasPrefix(resolved, "../")
+}
+
+// checkSymlinkParents refuses a path below a symlinked directory, since
+// writing or deleting through the link could reach outside baseDir
+func checkSymlinkParents(baseDir string, fileName string) error {
+	segments := strings.Split(fileName, "/")
+	parent := baseDir
+	for _, segment := range segments[:len(segments)-1] {
+		parent = filepath.Join(parent, segment)
+		info, err := os.Lstat(parent)
+		if os.IsNotExist(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if isSymlink(info) {
+			return fmt.Errorf("%w: %q lies below the symlink %q", ErrUnsafeSymlink, fileName, parent)
+		}
+	}
+	return nil
+}
+
+// createSymlink points filePath at target, replacing whatever was there. The
+// link is made under a temporary name and renamed into place.
+func createSymlink(filePath string, target string) error {
+	parentDir := filepath.Dir(filePath)
+	if err := os.MkdirAll(parentDir, 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(parentDir, TEMPFILE_PREFIX+"*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	tmp.Close()
+	if err := os.Remove(tmpPath); err != nil {
+		return err
+	}
+	if err := os.Symlink(target, tmpPath); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, filePath); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
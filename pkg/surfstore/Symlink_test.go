@@ -0,0 +1,178 @@
+package surfstore
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func readTestLink(t *testing.T, baseDir, fileName string) string {
+	t.Helper()
+	path := ConcatPath(baseDir, fileName)
+	info, err := os.Lstat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isSymlink(info) {
+		t.Fatalf("%s is not a symlink: %v", fileName, info.Mode())
+	}
+	target, err := os.Readlink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return target
+}
+
+func skippedFiles(report *SyncReport) map[string]error {
+	skipped := make(map[string]error)
+	for _, fileAction := range report.Files {
+		if fileAction.Action == ActionSkip {
+			skipped[fileAction.FileName] = fileAction.Err
+		}
+	}
+	return skipped
+}
+
+func TestSyncRoundTripsSymlinks(t *testing.T) {
+	client := newFakeClient()
+	dirA, dirB := t.TempDir(), t.TempDir()
+	outside := t.TempDir()
+	writeTestFile(t, outside, "secret.txt", "secret\n")
+	writeTestFile(t, dirA, "a.txt", "a\n")
+	writeTestFile(t, dirA, "dir/b.txt", "b\n")
+	for link, target := range map[string]string{
+		"file-link": "a.txt",
+		"dir-link":  "dir",
+		"out-link":  outside,
+	} {
+		if err := os.Symlink(target, ConcatPath(dirA, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	syncDir(t, client, dirA)
+
+	// links are stored as their targets, nothing behind them is uploaded
+	var fileInfoMap map[string]*FileMetaData
+	if err := client.GetFileInfoMap(&fileInfoMap); err != nil {
+		t.Fatal(err)
+	}
+	for _, fileName := range []string{"file-link", "dir-link", "out-link"} {
+		if fileMetaData := fileInfoMap[fileName]; fileMetaData.GetSymlinkTarget() == "" || len(FileBlockHashes(fileMetaData)) != 0 {
+			t.Fatalf("%s was stored as %v", fileName, fileMetaData)
+		}
+	}
+	for fileName := range fileInfoMap {
+		if fileName == "dir-link/b.txt" || fileName == "out-link/secret.txt" {
+			t.Fatalf("Sync followed a link to %s", fileName)
+		}
+	}
+
+	syncDir(t, client, dirB)
+	for link, target := range map[string]string{
+		"file-link": "a.txt",
+		"dir-link":  "dir",
+		"out-link":  outside,
+	} {
+		if got := readTestLink(t, dirB, link); got != target {
+			t.Fatalf("%s points to %q, want %q", link, got, target)
+		}
+	}
+
+	// retargeting a link is a change like any other
+	if err := os.Remove(ConcatPath(dirA, "file-link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("dir/b.txt", ConcatPath(dirA, "file-link")); err != nil {
+		t.Fatal(err)
+	}
+	syncDir(t, client, dirA)
+	syncDir(t, client, dirB)
+	if got := readTestLink(t, dirB, "file-link"); got != "dir/b.txt" {
+		t.Fatalf("file-link points to %q after it was retargeted", got)
+	}
+	if got := readTestFile(t, dirB, "a.txt"); got != "a\n" {
+		t.Fatalf("the old link target was overwritten with %q", got)
+	}
+}
+
+func TestSyncRefusesUnsafeSymlinksOnPush(t *testing.T) {
+	client := newFakeClient()
+	baseDir := t.TempDir()
+	writeTestFile(t, baseDir, "dir/a.txt", "a\n")
+	for link, target := range map[string]string{
+		"dir/inside": "../dir/a.txt",
+		"dir/up":     "../../secret",
+		"absolute":   filepath.Join(t.TempDir(), "secret"),
+	} {
+		if err := os.Symlink(target, ConcatPath(baseDir, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	opts := DefaultSyncOptions(baseDir, 4)
+	opts.SafeLinks = true
+	report := syncWith(t, client, opts)
+
+	skipped := skippedFiles(report)
+	if len(skipped) != 2 || !errors.Is(skipped["dir/up"], ErrUnsafeSymlink) || !errors.Is(skipped["absolute"], ErrUnsafeSymlink) {
+		t.Fatalf("Sync skipped %v, want the two links leaving the base directory", skipped)
+	}
+	var fileInfoMap map[string]*FileMetaData
+	if err := client.GetFileInfoMap(&fileInfoMap); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fileInfoMap["dir/up"]; ok {
+		t.Fatal("an unsafe link was pushed")
+	}
+	if _, ok := fileInfoMap["absolute"]; ok {
+		t.Fatal("an unsafe link was pushed")
+	}
+	if fileInfoMap["dir/inside"].GetSymlinkTarget() != "../dir/a.txt" {
+		t.Fatalf("a link inside the base directory was not pushed: %v", fileInfoMap["dir/inside"])
+	}
+}
+
+// TestSyncDoesNotWriteThroughPulledSymlinks has the server list a link to
+// another directory and a file below it, and checks that nothing is
+// written outside the base directory
+func TestSyncDoesNotWriteThroughPulledSymlinks(t *testing.T) {
+	for _, safeLinks := range []bool{false, true} {
+		client := newFakeClient()
+		baseDir, outside := t.TempDir(), t.TempDir()
+		block := []byte("escaped\n")
+		if _, err := client.block.PutBlock(nil, &Block{BlockData: block, BlockSize: int32(len(block))}); err != nil {
+			t.Fatal(err)
+		}
+		for _, fileMetaData := range []*FileMetaData{
+			{Filename: "dir", Version: 1, SymlinkTarget: outside},
+			{Filename: "dir/escape.txt", Version: 1, BlockHashList: []string{GetBlockHashString(block)}, Size: int64(len(block))},
+		} {
+			if _, err := client.meta.ApplyUpdate(fileMetaData); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		opts := DefaultSyncOptions(baseDir, 4)
+		opts.SafeLinks = safeLinks
+		skipped := skippedFiles(syncWith(t, client, opts))
+		if safeLinks {
+			// the link is refused, the file lands in a real directory
+			if len(skipped) != 1 || !errors.Is(skipped["dir"], ErrUnsafeSymlink) {
+				t.Fatalf("SafeLinks on: Sync skipped %v, want the link refused", skipped)
+			}
+			if got := readTestFile(t, baseDir, "dir/escape.txt"); got != string(block) {
+				t.Fatalf("SafeLinks on: dir/escape.txt holds %q", got)
+			}
+		} else {
+			if readTestLink(t, baseDir, "dir") != outside {
+				t.Fatal("SafeLinks off: the link was not pulled")
+			}
+			if len(skipped) != 1 || !errors.Is(skipped["dir/escape.txt"], ErrUnsafeSymlink) {
+				t.Fatalf("SafeLinks off: Sync skipped %v, want dir/escape.txt refused", skipped)
+			}
+		}
+		if entries, err := os.ReadDir(outside); err != nil || len(entries) != 0 {
+			t.Fatalf("SafeLinks %v: Sync wrote %v outside the base directory (%v)", safeLinks, entries, err)
+		}
+	}
+}
@@ -0,0 +1,665 @@
+package surfstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// ErrUnsupportedFileType is reported for local files that cannot be synced
+var ErrUnsupportedFileType = fmt.Errorf("not a regular file, directory or symlink")
+
+// SyncOptions configures a sync of a base directory
+type SyncOptions struct {
+	BaseDir   string
+	BlockSize int
+	// Chunker splits files into blocks, fixed BlockSize blocks by default
+	Chunker Chunker
+	// Workers bounds how many blocks a sync transfers at once
+	Workers int
+	// Xattrs makes syncs carry the user extended attributes of files
+	Xattrs bool
+	// SafeLinks refuses symlinks that point outside BaseDir
+	SafeLinks bool
+	// ConflictResolver settles files changed locally and on the server,
+	// both versions are kept by default
+	ConflictResolver ConflictResolver
+	// Merge selects when text files changed on both sides are merged
+	// instead of handed to ConflictResolver, MERGE_CLEAN by default
+	Merge string
+}
+
+func DefaultSyncOptions(baseDir string, blockSize int) SyncOptions {
+	return SyncOptions{
+		BaseDir:          baseDir,
+		BlockSize:        blockSize,
+		Chunker:          &FixedChunker{BlockSize: blockSize},
+		Workers:          DEFAULT_TRANSFER_WORKERS,
+		ConflictResolver: &KeepBothResolver{},
+		Merge:            MERGE_CLEAN,
+	}
+}
+
+// withDefaults fills in the options left unset
+func (opts SyncOptions) withDefaults() SyncOptions {
+	defaults := DefaultSyncOptions(opts.BaseDir, opts.BlockSize)
+	if opts.Chunker == nil {
+		opts.Chunker = defaults.Chunker
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = defaults.Workers
+	}
+	if opts.ConflictResolver == nil {
+		opts.ConflictResolver = defaults.ConflictResolver
+	}
+	if opts.Merge == "" {
+		opts.Merge = defaults.Merge
+	}
+	return opts
+}
+
+// SyncError is returned by a failed Sync. Op is the stage that failed, one
+// of the SYNC_OP constants, and FileName the file it failed on, if any. Err
+// is the cause, e.g. context.Canceled, a *MissingBlocksError or a gRPC status.
+type SyncError struct {
+	Op       string
+	FileName string
+	Err      error
+}
+
+func (e *SyncError) Error() string {
+	if e.FileName == "" {
+		return fmt.Sprintf("%s: %v", e.Op, e.Err)
+	}
+	return fmt.Sprintf("%s %q: %v", e.Op, e.FileName, e.Err)
+}
+
+func (e *SyncError) Unwrap() error {
+	return e.Err
+}
+
+// Action is what a sync did with a file
+type Action int
+
+const (
+	// a local change was committed as a new version
+	ActionUpload Action = iota
+	// a local change of attributes only was committed
+	ActionUploadAttributes
+	// a local deletion was committed
+	ActionDeleteRemote
+	// the server's version was written to the local file
+	ActionDownload
+	// the attributes of the server's version were applied to the local file
+	ActionDownloadAttributes
+	// the local file was removed because it was deleted on the server
+	ActionDeleteLocal
+	// the file changed locally and on the server, see SyncStats.Conflicts
+	ActionConflict
+	// the file was left alone, FileAction.Err says why
+	ActionSkip
+)
+
+var actionNames = []string{
+	"upload", "upload attributes", "delete remote", "download",
+	"download attributes", "delete local", "conflict", "skip",
+}
+
+func (action Action) String() string {
+	if action < 0 || int(action) >= len(actionNames) {
+		return fmt.Sprintf("Action(%d)", int(action))
+	}
+	return actionNames[action]
+}
+
+func (action Action) MarshalText() ([]byte, error) {
+	return []byte(action.String()), nil
+}
+
+// FileAction is one action a sync took on a file
+type FileAction struct {
+	FileName string
+	Action   Action
+	// version of the file after the action
+	Version int32
+	// why the file was skipped
+	Err error
+}
+
+func (fileAction FileAction) String() string {
+	if fileAction.Action == ActionSkip {
+		return fmt.Sprintf("%v %q: %v", fileAction.Action, fileAction.FileName, fileAction.Err)
+	}
+	return fmt.Sprintf("%v %q version %d", fileAction.Action, fileAction.FileName, fileAction.Version)
+}
+
+// SyncReport lists what a sync did, in order. A failed sync reports what it
+// did before the failure.
+type SyncReport struct {
+	Files []FileAction
+	Stats *SyncStats
+}
+
+func (report *SyncReport) add(fileName string, action Action, version int32) {
+	report.Files = append(report.Files, FileAction{FileName: fileName, Action: action, Version: version})
+}
+
+func (report *SyncReport) skip(fileName string, err error) {
+	report.Files = append(report.Files, FileAction{FileName: fileName, Action: ActionSkip, Err: err})
+}
+
+// Sync makes the base directory and the server hold the same files: local
+// changes are pushed, then the server's versions are pulled and recorded in
+// index.db. Errors are returned as a *SyncError. A canceled ctx stops the
+// sync before its next file or block transfer; calls already sent are
+// bounded by the client's own deadlines.
+func Sync(ctx context.Context, client ClientInterface, opts SyncOptions) (*SyncReport, error) {
+	opts = opts.withDefaults()
+	client = withContext(ctx, client)
+	baseDir := opts.BaseDir
+	report := &SyncReport{Stats: &SyncStats{}}
+	fail := func(op string, fileName string, err error) (*SyncReport, error) {
+		return report, &SyncError{Op: op, FileName: fileName, Err: err}
+	}
+	log.Printf("block size is:%v\n", opts.BlockSize)
+
+	log.Println("step1")
+	// step1: fetch local file info
+	scan, err := scanLocal(ctx, opts, report.skip)
+	if err != nil {
+		return fail(SYNC_OP_SCAN, "", err)
+	}
+	localMetaMap := scan.metaMap
+	localDirs := scan.dirs
+	localBlocks := scan.blocks
+
+	log.Println("step2")
+	// step2: fetch local index.db map
+	localMap, err := LoadMetaFromMetaFile(baseDir)
+	if err != nil {
+		return fail(SYNC_OP_INDEX, "", err)
+	}
+	localIndexMap := validIndexEntries(localMap)
+
+	log.Println("step3")
+	// step3: push all local changes to cloud, on top of the versions the
+	// server holds now
+	serverIndexMap := make(map[string]*FileMetaData)
+	err = client.GetFileInfoMap(&serverIndexMap)
+	if err != nil {
+		return fail(SYNC_OP_LIST, "", err)
+	}
+	// entries that cannot be pulled are reported in step4
+	dropInvalidRemoteEntries(opts, serverIndexMap, func(string, error) {})
+	stats := report.Stats
+	// local versions that lose to the server's version, by filename
+	conflicted := make(map[string]*FileMetaData)
+	fileNames := make(map[string]bool)
+	for fileName := range localMetaMap {
+		fileNames[fileName] = true
+	}
+	for fileName := range localIndexMap {
+		fileNames[fileName] = true
+	}
+	for fileName := range fileNames {
+		if err := ctx.Err(); err != nil {
+			return fail(SYNC_OP_PUSH, fileName, err)
+		}
+		decision, err := decidePush(opts, scan, fileName, localIndexMap[fileName], serverIndexMap[fileName])
+		if err != nil {
+			return fail(SYNC_OP_PUSH, fileName, err)
+		}
+		if decision == nil {
+			continue
+		}
+		newFileMetaData := decision.version
+		if decision.action == ActionConflict {
+			// resolved in step5, once the winning version is known
+			conflicted[fileName] = newFileMetaData
+			continue
+		}
+		blockStoreMap := make(map[string][]string)
+		if decision.action != ActionDeleteRemote {
+			err = client.GetBlockStoreMap(newFileMetaData.GetBlockHashList(), &blockStoreMap)
+			if err != nil {
+				return fail(SYNC_OP_PUSH, fileName, err)
+			}
+		}
+		if decision.action == ActionUploadAttributes {
+			// the blocks were stored with an earlier version
+			err = updateFileVersion(ctx, client, opts, newFileMetaData, localBlocks, blockStoreMap, stats)
+		} else {
+			err = Push(ctx, client, opts, newFileMetaData, localBlocks, blockStoreMap, stats)
+		}
+		if errors.Is(err, ErrVersionConflict) {
+			// another client committed first. Losing a chmod loses no
+			// content, and a directory always loses to the server's version.
+			if decision.action != ActionUploadAttributes && !localDirs[fileName] {
+				conflicted[fileName] = newFileMetaData
+			}
+		} else if err != nil {
+			return fail(SYNC_OP_PUSH, fileName, err)
+		} else {
+			report.add(fileName, decision.action, newFileMetaData.GetVersion())
+		}
+	}
+
+	log.Println("step4")
+	// step4: fetch remote index.db map
+	remoteIndexMap := make(map[string]*FileMetaData)
+	err = client.GetFileInfoMap(&remoteIndexMap)
+	if err != nil {
+		return fail(SYNC_OP_LIST, "", err)
+	}
+	dropInvalidRemoteEntries(opts, remoteIndexMap, report.skip)
+
+	log.Println("step5")
+	// step5: pull remote changes to local
+	// local changes that lost to another client's version are merged with
+	// it if possible, or settled by the conflict resolver, before the
+	// winning version is pulled over them
+	host := conflictHost()
+	taken := func(name string) bool {
+		_, remote := remoteIndexMap[name]
+		_, local := localMetaMap[name]
+		_, err := os.Lstat(ConcatPath(baseDir, name))
+		return remote || local || err == nil
+	}
+	// settleConflict merges a file whose local version lost to remote or
+	// applies the resolver's decision, and reports whether remote must still
+	// be pulled
+	settleConflict := func(fileName string, lost *FileMetaData, remote *FileMetaData) (bool, error) {
+		if opts.Merge != MERGE_OFF {
+			merged, err := mergeConflict(ctx, client, opts, localIndexMap[fileName], lost, remote, localBlocks, stats)
+			if err != nil {
+				return false, err
+			}
+			if merged != nil {
+				remoteIndexMap[fileName] = merged
+				report.add(fileName, ActionConflict, merged.GetVersion())
+				return false, nil
+			}
+		}
+		resolution := opts.ConflictResolver.Resolve(fileName, localIndexMap[fileName], lost, remote)
+		if resolution == KeepLocal {
+			// commit the local version again, on top of the winner
+			forced := proto.Clone(lost).(*FileMetaData)
+			forced.Version = remote.GetVersion() + 1
+			forcedBlockStoreMap := make(map[string][]string)
+			err := client.GetBlockStoreMap(FileBlockHashes(forced), &forcedBlockStoreMap)
+			if err != nil {
+				return false, err
+			}
+			err = Push(ctx, client, opts, forced, localBlocks, forcedBlockStoreMap, stats)
+			if err == nil {
+				remoteIndexMap[fileName] = forced
+				stats.addConflict(fileName, "", KeepLocal)
+				report.add(fileName, ActionConflict, forced.GetVersion())
+				return false, nil
+			} else if !errors.Is(err, ErrVersionConflict) {
+				return false, err
+			}
+			// yet another version won meanwhile
+			resolution = KeepBoth
+		}
+		report.add(fileName, ActionConflict, remote.GetVersion())
+		if resolution == KeepBoth && !IsTombstone(lost) {
+			copyName := ConflictCopyName(fileName, host, time.Now(), taken)
+			conflictCopy, err := saveConflictCopy(ctx, client, opts, lost, copyName, localBlocks, stats)
+			if err != nil {
+				return false, err
+			}
+			remoteIndexMap[copyName] = conflictCopy
+			report.add(copyName, ActionUpload, conflictCopy.GetVersion())
+			return true, nil
+		}
+		stats.addConflict(fileName, "", KeepRemote)
+		return true, nil
+	}
+	// check newly created or modified file on cloud
+	for _, fileName := range pullOrder(remoteIndexMap) {
+		if err := ctx.Err(); err != nil {
+			return fail(SYNC_OP_PULL, fileName, err)
+		}
+		fileMetaData := remoteIndexMap[fileName]
+		decision, err := decidePull(opts, scan, fileName, fileMetaData)
+		if err != nil {
+			return fail(SYNC_OP_PULL, fileName, err)
+		}
+		if decision == nil {
+			// up to date, or deleted on both sides
+			continue
+		}
+		if decision.action == ActionDownloadAttributes {
+			// same content, only attributes changed
+			err := applyAttributes(ConcatPath(baseDir, fileName), fileMetaData, opts.Xattrs)
+			if err != nil {
+				return fail(SYNC_OP_PULL, fileName, err)
+			}
+			report.add(fileName, ActionDownloadAttributes, fileMetaData.GetVersion())
+			continue
+		}
+		if lost, ok := conflicted[fileName]; ok {
+			pull, err := settleConflict(fileName, lost, fileMetaData)
+			if err != nil {
+				return fail(SYNC_OP_CONFLICT, fileName, err)
+			}
+			if !pull {
+				continue
+			}
+		}
+		// local version is out-dated or missing
+		blockStoreMap := make(map[string][]string)
+		err = client.GetBlockStoreMap(FileBlockHashes(fileMetaData), &blockStoreMap)
+		if err != nil {
+			return fail(SYNC_OP_PULL, fileName, err)
+		}
+		err = Pull(ctx, client, opts, fileMetaData, blockStoreMap, localBlocks, stats)
+		if errors.Is(err, ErrUnsafeSymlink) {
+			report.skip(fileName, err)
+			delete(remoteIndexMap, fileName)
+			continue
+		} else if err != nil {
+			return fail(SYNC_OP_PULL, fileName, err)
+		}
+		report.add(fileName, decision.action, fileMetaData.GetVersion())
+	}
+
+	log.Println("step6")
+	// step6: sync local index.db
+	err = WriteMetaFile(remoteIndexMap, baseDir)
+	if err != nil {
+		return fail(SYNC_OP_INDEX, "", err)
+	}
+	return report, nil
+}
+
+// fileDecision is what a sync does with one file. version is the version a
+// push commits, or for a conflict the local version that loses to the
+// server's.
+type fileDecision struct {
+	action  Action
+	version *FileMetaData
+}
+
+// decideFile decides what a sync does with a file given its version in
+// index.db and on the server, either may be nil. Sync makes the two halves
+// of the decision at different times: decidePush before it pushes, and
+// decidePull for the versions the server holds once it pushed. Plan makes
+// both at once. nil means nothing is done.
+func decideFile(opts SyncOptions, scan *localScan, fileName string, base *FileMetaData, remote *FileMetaData) (*fileDecision, error) {
+	decision, err := decidePush(opts, scan, fileName, base, remote)
+	if err != nil || decision != nil {
+		return decision, err
+	}
+	return decidePull(opts, scan, fileName, remote)
+}
+
+// decidePush decides which local change of a file is committed on top of
+// remote: an upload, a change of attributes or a deletion. A conflict means
+// the local change is settled against remote once it is pulled. nil means
+// there is no local change, or one that pulling remote settles: the same
+// change made on both sides, or a directory, which always loses.
+func decidePush(opts SyncOptions, scan *localScan, fileName string, base *FileMetaData, remote *FileMetaData) (*fileDecision, error) {
+	if scan.skipped[fileName] {
+		return nil, nil
+	}
+	if remote == nil {
+		// the server lost the file or never had it, so it only takes the
+		// file as new at version 1, whatever index.db recorded
+		base = nil
+	}
+	// the version the server holds now is the one a push builds on
+	current := remote == nil || (base != nil && remote.GetVersion() == base.GetVersion())
+
+	if _, local := scan.metaMap[fileName]; !local {
+		if base == nil || IsTombstone(base) || (!current && IsTombstone(remote)) {
+			// nothing to delete, or deleted on both sides
+			return nil, nil
+		}
+		if current {
+			return &fileDecision{action: ActionDeleteRemote, version: NewTombstone(base)}, nil
+		}
+		return &fileDecision{action: ActionConflict, version: NewTombstone(base)}, nil
+	}
+
+	changed := base == nil
+	if !changed {
+		matches, err := scan.matches(opts, fileName, base)
+		if err != nil {
+			return nil, err
+		}
+		changed = !matches
+	}
+	if changed {
+		if current {
+			return &fileDecision{action: ActionUpload, version: scan.newVersion(opts, fileName, base)}, nil
+		}
+		matches, err := scan.matches(opts, fileName, remote)
+		if err != nil || matches || scan.dirs[fileName] {
+			return nil, err
+		}
+		return &fileDecision{action: ActionConflict, version: scan.newVersion(opts, fileName, base)}, nil
+	}
+	if current && !SameAttributes(scan.attrs[fileName], base, opts.Xattrs) {
+		// only attributes changed, e.g. by chmod: the new version keeps the
+		// recorded blocks
+		newFileMetaData := proto.Clone(base).(*FileMetaData)
+		newFileMetaData.Version++
+		newFileMetaData.Size = scan.sizes[fileName]
+		setAttributes(newFileMetaData, scan.attrs[fileName], base, opts.Xattrs)
+		return &fileDecision{action: ActionUploadAttributes, version: newFileMetaData}, nil
+	}
+	return nil, nil
+}
+
+// decidePull decides what pulling remote does to the local file: a
+// download, a change of attributes or a deletion. A file skipped by the
+// scan is replaced by a download like a missing one. nil means nothing.
+func decidePull(opts SyncOptions, scan *localScan, fileName string, remote *FileMetaData) (*fileDecision, error) {
+	if remote == nil {
+		return nil, nil
+	}
+	_, local := scan.metaMap[fileName]
+	if local {
+		matches, err := scan.matches(opts, fileName, remote)
+		if err != nil {
+			return nil, err
+		}
+		if matches {
+			if !SameAttributes(scan.attrs[fileName], remote, opts.Xattrs) {
+				return &fileDecision{action: ActionDownloadAttributes}, nil
+			}
+			return nil, nil
+		}
+	}
+	if IsTombstone(remote) {
+		if !local {
+			return nil, nil
+		}
+		return &fileDecision{action: ActionDeleteLocal}, nil
+	}
+	return &fileDecision{action: ActionDownload}, nil
+}
+
+// localScan is what the first step of a sync found in the base directory.
+// Files are named by their slash-separated path relative to it.
+type localScan struct {
+	metaMap map[string][]string
+	dirs    map[string]bool
+	attrs   map[string]*FileMetaData
+	links   map[string]string
+	sizes   map[string]int64
+	// files that exist but are not synced, they must not look deleted
+	skipped map[string]bool
+	blocks  LocalBlockIndex
+}
+
+// scanLocal hashes every file below the base directory. Directories are
+// entries of their own, so empty ones are synced too. Files that cannot be
+// synced are passed to skip.
+func scanLocal(ctx context.Context, opts SyncOptions, skip func(fileName string, err error)) (*localScan, error) {
+	baseDir := opts.BaseDir
+	scan := &localScan{
+		metaMap: make(map[string][]string),
+		dirs:    make(map[string]bool),
+		attrs:   make(map[string]*FileMetaData),
+		links:   make(map[string]string),
+		sizes:   make(map[string]int64),
+		skipped: make(map[string]bool),
+		blocks:  make(LocalBlockIndex),
+	}
+	err := filepath.Walk(baseDir, func(path string, info os.FileInfo, err error) error {
+		if err == nil {
+			err = ctx.Err()
+		}
+		if err != nil {
+			return err
+		}
+		if path == baseDir {
+			return nil
+		}
+		relPath, err := filepath.Rel(baseDir, path)
+		if err != nil {
+			return err
+		}
+		fileName := filepath.ToSlash(relPath)
+		if !info.IsDir() && (fileName == DEFAULT_META_FILENAME || strings.HasPrefix(info.Name(), TEMPFILE_PREFIX)) {
+			return nil
+		}
+		if err := ValidateFilename(fileName); err != nil {
+			skip(fileName, err)
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if isSymlink(info) {
+			// a link is synced as its target, never as the target's bytes
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			if opts.SafeLinks && SymlinkEscapes(fileName, target) {
+				skip(fileName, fmt.Errorf("%w: %q points to %q outside the base directory", ErrUnsafeSymlink, fileName, target))
+				scan.skipped[fileName] = true
+				return nil
+			}
+			scan.links[fileName] = target
+			scan.attrs[fileName] = &FileMetaData{}
+			scan.metaMap[fileName] = []string{EMPTYFILE_HASHVALUE}
+			return nil
+		}
+		if !info.IsDir() && !info.Mode().IsRegular() {
+			skip(fileName, fmt.Errorf("%q is %w", fileName, ErrUnsupportedFileType))
+			scan.skipped[fileName] = true
+			return nil
+		}
+		attrs, err := readAttributes(path, info, opts.Xattrs)
+		if err != nil {
+			return err
+		}
+		scan.attrs[fileName] = attrs
+		if info.IsDir() {
+			scan.dirs[fileName] = true
+			scan.metaMap[fileName] = []string{EMPTYFILE_HASHVALUE}
+			return nil
+		}
+		hashList, size, err := chunkLocalFile(path, opts.Chunker, scan.blocks)
+		if err != nil {
+			return err
+		}
+		scan.metaMap[fileName] = hashList
+		scan.sizes[fileName] = size
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return scan, nil
+}
+
+// matches compares a local file with a recorded version of it. A version
+// cut with other chunking parameters, e.g. by a client with another block
+// size, is compared by cutting the local file the same way.
+func (scan *localScan) matches(opts SyncOptions, fileName string, fileMetaData *FileMetaData) (bool, error) {
+	if scan.dirs[fileName] || fileMetaData.GetDirectory() {
+		return scan.dirs[fileName] && fileMetaData.GetDirectory() && !IsTombstone(fileMetaData), nil
+	}
+	if target, ok := scan.links[fileName]; ok || fileMetaData.GetSymlinkTarget() != "" {
+		return ok && target == fileMetaData.GetSymlinkTarget() && !IsTombstone(fileMetaData), nil
+	}
+	if IsTombstone(fileMetaData) {
+		return false, nil
+	}
+	hashList := scan.metaMap[fileName]
+	if !SameChunking(opts.Chunker, fileMetaData, opts.BlockSize) {
+		otherChunker, err := ChunkerFor(fileMetaData, opts.BlockSize)
+		if err != nil {
+			return false, nil
+		}
+		hashList, _, err = chunkLocalFile(ConcatPath(opts.BaseDir, fileName), otherChunker, scan.blocks)
+		if err != nil {
+			return false, err
+		}
+	}
+	return CompareHashLists(stripEmptyFileMarker(hashList), FileBlockHashes(fileMetaData)), nil
+}
+
+// newVersion is the version a push of a local file commits on top of prev,
+// nil for a file new to the server
+func (scan *localScan) newVersion(opts SyncOptions, fileName string, prev *FileMetaData) *FileMetaData {
+	fileMetaData := &FileMetaData{
+		Filename:      fileName,
+		Version:       prev.GetVersion() + 1,
+		BlockHashList: scan.metaMap[fileName],
+		Directory:     scan.dirs[fileName],
+		SymlinkTarget: scan.links[fileName],
+		Size:          scan.sizes[fileName],
+	}
+	opts.Chunker.Describe(fileMetaData)
+	setAttributes(fileMetaData, scan.attrs[fileName], prev, opts.Xattrs)
+	return fileMetaData
+}
+
+// validIndexEntries drops the entries of a local index.db that no valid
+// version could have produced
+func validIndexEntries(localMap map[string]*FileMetaData) map[string]*FileMetaData {
+	localIndexMap := make(map[string]*FileMetaData)
+	for k, v := range localMap {
+		if err := ValidateFileMetaData(v); err != nil {
+			log.Printf("Skipping index.db entry: %v\n", err)
+			continue
+		}
+		localIndexMap[k] = v
+	}
+	return localIndexMap
+}
+
+// dropInvalidRemoteEntries removes the versions listed by the server that
+// must not be pulled and passes them to skip. Names from the server are
+// never trusted, they are joined with the base directory.
+func dropInvalidRemoteEntries(opts SyncOptions, remoteIndexMap map[string]*FileMetaData, skip func(fileName string, err error)) {
+	for fileName, fileMetaData := range remoteIndexMap {
+		err := ValidateFileMetaData(fileMetaData)
+		if err == nil && fileMetaData.GetFilename() != fileName {
+			err = fmt.Errorf("%w %q: listed as %q", ErrInvalidFilename, fileMetaData.GetFilename(), fileName)
+		}
+		if target := fileMetaData.GetSymlinkTarget(); err == nil && opts.SafeLinks && target != "" && SymlinkEscapes(fileName, target) {
+			err = fmt.Errorf("%w: %q points to %q outside the base directory", ErrUnsafeSymlink, fileName, target)
+		}
+		if err != nil {
+			skip(fileName, err)
+			delete(remoteIndexMap, fileName)
+			continue
+		}
+		UpgradeFileMetaData(fileMetaData)
+	}
+}
@@ -0,0 +1,521 @@
+package surfstore
+
+import (
+	context "context"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const fakeBlockStoreAddr string = "blockstore"
+
+// fakeClient is a ClientInterface backed by an in-process MetaStore and
+// BlockStore. It counts the calls it serves, and beforeUpdate, if set, runs
+// before each UpdateFile, e.g. to let another client win a version.
+type fakeClient struct {
+	meta  *MetaStore
+	block *BlockStore
+
+	mu             sync.Mutex
+	calls          map[string]int
+	beforeUpdate   func(fileMetaData *FileMetaData)
+	beforePutBlock func()
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{
+		meta:  NewMetaStore([]string{fakeBlockStoreAddr}),
+		block: NewBlockStore(),
+		calls: make(map[string]int),
+	}
+}
+
+func (f *fakeClient) count(method string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls[method]++
+}
+
+func (f *fakeClient) called(method string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[method]
+}
+
+func (f *fakeClient) GetFileInfoMap(serverFileInfoMap *map[string]*FileMetaData) error {
+	f.count("GetFileInfoMap")
+	fileInfoMap, err := f.meta.GetFileInfoMap(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+	*serverFileInfoMap = fileInfoMap.GetFileInfoMap()
+	return nil
+}
+
+// UpdateFile checks blocks against the BlockStore, as the MetaStore does
+// over gRPC
+func (f *fakeClient) UpdateFile(fileMetaData *FileMetaData, latestVersion *int32) error {
+	f.count("UpdateFile")
+	if f.beforeUpdate != nil {
+		f.beforeUpdate(fileMetaData)
+	}
+	if err := ValidateFileMetaData(fileMetaData); err != nil {
+		return err
+	}
+	missing, err := f.block.MissingBlocks(context.Background(), &BlockHashes{Hashes: FileBlockHashes(fileMetaData)})
+	if err != nil {
+		return err
+	}
+	if len(missing.GetHashes()) > 0 {
+		*latestVersion = -1
+		return &MissingBlocksError{Hashes: missing.GetHashes()}
+	}
+	v, err := f.meta.ApplyUpdate(fileMetaData)
+	if err != nil {
+		return err
+	}
+	*latestVersion = v.GetVersion()
+	return nil
+}
+
+func (f *fakeClient) GetBlockStoreMap(blockHashesIn []string, blockStoreMap *map[string][]string) error {
+	f.count("GetBlockStoreMap")
+	*blockStoreMap = map[string][]string{fakeBlockStoreAddr: blockHashesIn}
+	return nil
+}
+
+func (f *fakeClient) GetBlockStoreAddrs(blockStoreAddrs *[]string) error {
+	f.count("GetBlockStoreAddrs")
+	*blockStoreAddrs = []string{fakeBlockStoreAddr}
+	return nil
+}
+
+func (f *fakeClient) GetFileHistory(fileName string, versions *[]*FileMetaData) error {
+	f.count("GetFileHistory")
+	history, err := f.meta.GetFileHistory(context.Background(), &FileName{Filename: fileName})
+	if err != nil {
+		return err
+	}
+	*versions = history.GetVersions()
+	return nil
+}
+
+func (f *fakeClient) RestoreFileVersion(fileName string, version int32, latestVersion *int32) error {
+	f.count("RestoreFileVersion")
+	restored, err := f.meta.restoredVersion(&FileVersion{Filename: fileName, Version: version})
+	if err != nil {
+		return err
+	}
+	return f.UpdateFile(restored, latestVersion)
+}
+
+func (f *fakeClient) GetBlock(blockHash string, blockStoreAddr string, block *Block) error {
+	f.count("GetBlock")
+	b, err := f.block.GetBlock(context.Background(), &BlockHash{Hash: blockHash})
+	if err != nil {
+		return err
+	}
+	block.BlockData = b.GetBlockData()
+	block.BlockSize = b.GetBlockSize()
+	return nil
+}
+
+func (f *fakeClient) PutBlock(block *Block, blockStoreAddr string, succ *bool) error {
+	f.count("PutBlock")
+	if f.beforePutBlock != nil {
+		f.beforePutBlock()
+	}
+	s, err := f.block.PutBlock(context.Background(), block)
+	if err != nil {
+		return err
+	}
+	*succ = s.GetFlag()
+	return nil
+}
+
+func (f *fakeClient) MissingBlocks(blockHashesIn []string, blockStoreAddr string, blockHashesOut *[]string) error {
+	f.count("MissingBlocks")
+	missing, err := f.block.MissingBlocks(context.Background(), &BlockHashes{Hashes: blockHashesIn})
+	if err != nil {
+		return err
+	}
+	*blockHashesOut = missing.GetHashes()
+	return nil
+}
+
+func (f *fakeClient) GetBlockHashes(blockStoreAddr string, blockHashes *[]string) error {
+	f.count("GetBlockHashes")
+	hashes, err := f.block.GetBlockHashes(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+	*blockHashes = hashes.GetHashes()
+	return nil
+}
+
+// This line guarantees all method for fakeClient are implemented
+var _ ClientInterface = new(fakeClient)
+
+func TestMain(m *testing.M) {
+	// Sync logs every step
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+func writeTestFile(t *testing.T, baseDir, fileName, content string) {
+	t.Helper()
+	path := ConcatPath(baseDir, fileName)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func syncDir(t *testing.T, client ClientInterface, baseDir string) *SyncReport {
+	t.Helper()
+	return syncWith(t, client, DefaultSyncOptions(baseDir, 4))
+}
+
+func syncWith(t *testing.T, client ClientInterface, opts SyncOptions) *SyncReport {
+	t.Helper()
+	report, err := Sync(context.Background(), client, opts)
+	if err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	return report
+}
+
+func readTestFile(t *testing.T, baseDir, fileName string) string {
+	t.Helper()
+	data, err := os.ReadFile(ConcatPath(baseDir, fileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestSyncReportsEveryAction(t *testing.T) {
+	client := newFakeClient()
+	dirA, dirB := t.TempDir(), t.TempDir()
+	writeTestFile(t, dirA, "new.txt", "new\n")
+	writeTestFile(t, dirA, "changed.txt", "old\n")
+	writeTestFile(t, dirA, "deleted.txt", "deleted\n")
+	writeTestFile(t, dirA, "dir/nested.txt", "nested\n")
+	report := syncDir(t, client, dirA)
+	want := []FileAction{
+		{FileName: "new.txt", Action: ActionUpload, Version: 1},
+		{FileName: "changed.txt", Action: ActionUpload, Version: 1},
+		{FileName: "deleted.txt", Action: ActionUpload, Version: 1},
+		{FileName: "dir", Action: ActionUpload, Version: 1},
+		{FileName: "dir/nested.txt", Action: ActionUpload, Version: 1},
+	}
+	if !sameFileActions(report.Files, want) {
+		t.Fatalf("first sync reported %v, want %v", report.Files, want)
+	}
+	if report.Stats.BlocksUploaded == 0 {
+		t.Fatalf("first sync uploaded no blocks: %v", report.Stats)
+	}
+
+	report = syncDir(t, client, dirB)
+	want = []FileAction{
+		{FileName: "new.txt", Action: ActionDownload, Version: 1},
+		{FileName: "changed.txt", Action: ActionDownload, Version: 1},
+		{FileName: "deleted.txt", Action: ActionDownload, Version: 1},
+		{FileName: "dir", Action: ActionDownload, Version: 1},
+		{FileName: "dir/nested.txt", Action: ActionDownload, Version: 1},
+	}
+	if !sameFileActions(report.Files, want) {
+		t.Fatalf("second client reported %v, want %v", report.Files, want)
+	}
+
+	writeTestFile(t, dirA, "changed.txt", "new content\n")
+	if err := os.Remove(ConcatPath(dirA, "deleted.txt")); err != nil {
+		t.Fatal(err)
+	}
+	report = syncDir(t, client, dirA)
+	want = []FileAction{
+		{FileName: "changed.txt", Action: ActionUpload, Version: 2},
+		{FileName: "deleted.txt", Action: ActionDeleteRemote, Version: 2},
+	}
+	if !sameFileActions(report.Files, want) {
+		t.Fatalf("sync of the changes reported %v, want %v", report.Files, want)
+	}
+
+	report = syncDir(t, client, dirB)
+	want = []FileAction{
+		{FileName: "changed.txt", Action: ActionDownload, Version: 2},
+		{FileName: "deleted.txt", Action: ActionDeleteLocal, Version: 2},
+	}
+	if !sameFileActions(report.Files, want) {
+		t.Fatalf("second client reported %v, want %v", report.Files, want)
+	}
+	if got := readTestFile(t, dirB, "changed.txt"); got != "new content\n" {
+		t.Fatalf("second client holds %q", got)
+	}
+	if _, err := os.Stat(ConcatPath(dirB, "deleted.txt")); !os.IsNotExist(err) {
+		t.Fatalf("deleted file is still there: %v", err)
+	}
+
+	if report := syncDir(t, client, dirB); len(report.Files) != 0 {
+		t.Fatalf("a sync without changes reported %v", report.Files)
+	}
+}
+
+func TestSyncReportsSkippedFiles(t *testing.T) {
+	client := newFakeClient()
+	baseDir := t.TempDir()
+	writeTestFile(t, baseDir, "a.txt", "a\n")
+	// a valid name on Linux, but not on the server
+	writeTestFile(t, baseDir, "dir/b\\c", "b\n")
+	report := syncDir(t, client, baseDir)
+	var skipped []FileAction
+	for _, fileAction := range report.Files {
+		if fileAction.Action == ActionSkip {
+			skipped = append(skipped, fileAction)
+		}
+	}
+	if len(skipped) != 1 || skipped[0].FileName != "dir/b\\c" || !errors.Is(skipped[0].Err, ErrInvalidFilename) {
+		t.Fatalf("Sync reported %v, want the invalid name skipped", report.Files)
+	}
+}
+
+func TestSyncCanceled(t *testing.T) {
+	client := newFakeClient()
+	baseDir := t.TempDir()
+	writeTestFile(t, baseDir, "a.txt", "hello\n")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	report, err := Sync(ctx, client, DefaultSyncOptions(baseDir, 4))
+	var syncErr *SyncError
+	if !errors.As(err, &syncErr) || !errors.Is(err, context.Canceled) {
+		t.Fatalf("Sync with a canceled context: got %v, want a *SyncError wrapping context.Canceled", err)
+	}
+	if syncErr.Op != SYNC_OP_SCAN || report == nil || len(report.Files) != 0 {
+		t.Fatalf("Sync with a canceled context failed in %q after %v", syncErr.Op, report)
+	}
+	if client.called("UpdateFile") != 0 {
+		t.Fatalf("a canceled sync committed a version")
+	}
+}
+
+func TestSyncCanceledDuringUpload(t *testing.T) {
+	client := newFakeClient()
+	baseDir := t.TempDir()
+	writeTestFile(t, baseDir, "a.txt", strings.Repeat("block", 100))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client.beforePutBlock = cancel
+	opts := DefaultSyncOptions(baseDir, 4)
+	opts.Workers = 1
+	_, err := Sync(ctx, client, opts)
+	var syncErr *SyncError
+	if !errors.As(err, &syncErr) || !errors.Is(err, context.Canceled) {
+		t.Fatalf("Sync canceled during an upload: got %v, want a *SyncError wrapping context.Canceled", err)
+	}
+	if syncErr.Op != SYNC_OP_PUSH || syncErr.FileName != "a.txt" {
+		t.Fatalf("Sync canceled during an upload failed in %q %q", syncErr.Op, syncErr.FileName)
+	}
+	if client.called("PutBlock") != 1 || client.called("UpdateFile") != 0 {
+		t.Fatalf("Sync went on after it was canceled: %d blocks, %d updates", client.called("PutBlock"), client.called("UpdateFile"))
+	}
+	if _, err := os.Stat(ConcatPath(baseDir, DEFAULT_META_FILENAME)); !os.IsNotExist(err) {
+		t.Fatalf("a canceled sync wrote index.db: %v", err)
+	}
+}
+
+// TestSyncVersionConflicts covers each change a sync pushes losing to a
+// version another client committed first
+func TestSyncVersionConflicts(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		resolver ConflictResolver
+		// change made by this client after both synced "a.txt" version 1,
+		// nil for a new file
+		change func(t *testing.T, baseDir string)
+		// version the other client commits first
+		other string
+		want  func(t *testing.T, baseDir string, report *SyncReport)
+	}{{
+		name:     "new file, keep both",
+		resolver: &KeepBothResolver{},
+		other:    "theirs\n",
+		want: func(t *testing.T, baseDir string, report *SyncReport) {
+			conflicts := report.Stats.Conflicts
+			if len(conflicts) != 1 || conflicts[0].Resolution != KeepBoth || conflicts[0].ConflictCopy == "" {
+				t.Fatalf("Sync reported conflicts %v", conflicts)
+			}
+			want := []FileAction{
+				{FileName: "a.txt", Action: ActionConflict, Version: 1},
+				{FileName: conflicts[0].ConflictCopy, Action: ActionUpload, Version: 1},
+				{FileName: "a.txt", Action: ActionDownload, Version: 1},
+			}
+			if !sameFileActions(report.Files, want) {
+				t.Fatalf("Sync reported %v, want %v", report.Files, want)
+			}
+			if got := readTestFile(t, baseDir, "a.txt"); got != "theirs\n" {
+				t.Fatalf("a.txt holds %q, want the winning version", got)
+			}
+			if got := readTestFile(t, baseDir, conflicts[0].ConflictCopy); got != "mine\n" {
+				t.Fatalf("the conflict copy holds %q, want the local version", got)
+			}
+		},
+	}, {
+		name:     "changed file, keep local",
+		resolver: &ClientWinsResolver{},
+		change: func(t *testing.T, baseDir string) {
+			writeTestFile(t, baseDir, "a.txt", "mine\n")
+		},
+		other: "theirs\n",
+		want: func(t *testing.T, baseDir string, report *SyncReport) {
+			want := []FileAction{{FileName: "a.txt", Action: ActionConflict, Version: 3}}
+			if !sameFileActions(report.Files, want) {
+				t.Fatalf("Sync reported %v, want %v", report.Files, want)
+			}
+			if got := readTestFile(t, baseDir, "a.txt"); got != "mine\n" {
+				t.Fatalf("a.txt holds %q, want the local version", got)
+			}
+		},
+	}, {
+		name:     "changed file, keep remote",
+		resolver: &ServerWinsResolver{},
+		change: func(t *testing.T, baseDir string) {
+			writeTestFile(t, baseDir, "a.txt", "mine\n")
+		},
+		other: "theirs\n",
+		want: func(t *testing.T, baseDir string, report *SyncReport) {
+			want := []FileAction{
+				{FileName: "a.txt", Action: ActionConflict, Version: 2},
+				{FileName: "a.txt", Action: ActionDownload, Version: 2},
+			}
+			if !sameFileActions(report.Files, want) {
+				t.Fatalf("Sync reported %v, want %v", report.Files, want)
+			}
+			if got := readTestFile(t, baseDir, "a.txt"); got != "theirs\n" {
+				t.Fatalf("a.txt holds %q, want the winning version", got)
+			}
+		},
+	}, {
+		name:     "chmod loses without a conflict",
+		resolver: &KeepBothResolver{},
+		change: func(t *testing.T, baseDir string) {
+			if err := os.Chmod(ConcatPath(baseDir, "a.txt"), 0600); err != nil {
+				t.Fatal(err)
+			}
+		},
+		other: "theirs\n",
+		want: func(t *testing.T, baseDir string, report *SyncReport) {
+			want := []FileAction{{FileName: "a.txt", Action: ActionDownload, Version: 2}}
+			if !sameFileActions(report.Files, want) || len(report.Stats.Conflicts) != 0 {
+				t.Fatalf("Sync reported %v and conflicts %v, want %v", report.Files, report.Stats.Conflicts, want)
+			}
+		},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			client := newFakeClient()
+			dir, otherDir := t.TempDir(), t.TempDir()
+			if tc.change != nil {
+				writeTestFile(t, dir, "a.txt", "base\n")
+				syncDir(t, client, dir)
+				syncDir(t, client, otherDir)
+				tc.change(t, dir)
+			} else {
+				writeTestFile(t, dir, "a.txt", "mine\n")
+			}
+
+			// the other client commits just before this one does
+			client.beforeUpdate = func(fileMetaData *FileMetaData) {
+				client.beforeUpdate = nil
+				writeTestFile(t, otherDir, "a.txt", tc.other)
+				syncDir(t, client, otherDir)
+			}
+			opts := DefaultSyncOptions(dir, 4)
+			opts.ConflictResolver = tc.resolver
+			opts.Merge = MERGE_OFF
+			report := syncWith(t, client, opts)
+			tc.want(t, dir, report)
+			if client.beforeUpdate != nil {
+				t.Fatalf("the other client never committed")
+			}
+
+			// both clients agree once the other one syncs again
+			syncDir(t, client, otherDir)
+			if mine, theirs := readTestFile(t, dir, "a.txt"), readTestFile(t, otherDir, "a.txt"); mine != theirs {
+				t.Fatalf("clients disagree on a.txt: %q and %q", mine, theirs)
+			}
+		})
+	}
+}
+
+func TestSyncCommitsAttributeChangesWithoutBlocks(t *testing.T) {
+	client := newFakeClient()
+	baseDir := t.TempDir()
+	writeTestFile(t, baseDir, "a.txt", "hello\n")
+	syncDir(t, client, baseDir)
+
+	if err := os.Chmod(ConcatPath(baseDir, "a.txt"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	missingBlocks, putBlock := client.called("MissingBlocks"), client.called("PutBlock")
+	report := syncDir(t, client, baseDir)
+
+	want := []FileAction{{FileName: "a.txt", Action: ActionUploadAttributes, Version: 2}}
+	if !sameFileActions(report.Files, want) {
+		t.Fatalf("Sync reported %v, want %v", report.Files, want)
+	}
+	if report.Stats.BlocksDeduplicated != 0 || report.Stats.BlocksUploaded != 0 {
+		t.Fatalf("a chmod was counted as block traffic: %v", report.Stats)
+	}
+	if client.called("MissingBlocks") != missingBlocks || client.called("PutBlock") != putBlock {
+		t.Fatalf("a chmod asked BlockStores about its blocks")
+	}
+}
+
+// a chmod still commits if the BlockStore lost the blocks since, by sending
+// them again
+func TestSyncCommitsAttributeChangesAfterBlocksWereLost(t *testing.T) {
+	client := newFakeClient()
+	baseDir := t.TempDir()
+	writeTestFile(t, baseDir, "a.txt", "hello\n")
+	syncDir(t, client, baseDir)
+
+	client.block = NewBlockStore()
+	if err := os.Chmod(ConcatPath(baseDir, "a.txt"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	report := syncDir(t, client, baseDir)
+	want := []FileAction{{FileName: "a.txt", Action: ActionUploadAttributes, Version: 2}}
+	if !sameFileActions(report.Files, want) {
+		t.Fatalf("Sync reported %v, want %v", report.Files, want)
+	}
+	if report.Stats.BlocksUploaded != 2 {
+		t.Fatalf("uploaded %d blocks, want the 2 lost ones", report.Stats.BlocksUploaded)
+	}
+}
+
+// sameFileActions compares reports regardless of order, since a sync
+// visits files in map order
+func sameFileActions(got, want []FileAction) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	byName := func(a, b FileAction) int { return strings.Compare(a.FileName, b.FileName) }
+	got, want = slices.Clone(got), slices.Clone(want)
+	slices.SortStableFunc(got, byName)
+	slices.SortStableFunc(want, byName)
+	for i := range got {
+		if got[i].FileName != want[i].FileName || got[i].Action != want[i].Action || got[i].Version != want[i].Version {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,86 @@
+//go:build linux
+
+package surfstore
+
+import (
+	"bytes"
+	"strings"
+	"syscall"
+)
+
+// only the user namespace is synced, the others hold security labels and
+// ACLs that need privileges or mean nothing on another machine
+const xattrNamespace = "user."
+
+func getXattrs(path string) (map[string][]byte, error) {
+	list, err := readXattr(func(buf []byte) (int, error) {
+		return syscall.Listxattr(path, buf)
+	})
+	if err == syscall.ENOTSUP {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	xattrs := make(map[string][]byte)
+	for _, name := range bytes.Split(list, []byte{0}) {
+		if !strings.HasPrefix(string(name), xattrNamespace) {
+			continue
+		}
+		value, err := readXattr(func(buf []byte) (int, error) {
+			return syscall.Getxattr(path, string(name), buf)
+		})
+		if err == syscall.ENODATA {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		xattrs[string(name)] = value
+	}
+	return xattrs, nil
+}
+
+// setXattrs makes the user xattrs of a file exactly xattrs
+func setXattrs(path string, xattrs map[string][]byte) error {
+	current, err := getXattrs(path)
+	if err != nil {
+		return err
+	}
+	for name := range current {
+		if _, ok := xattrs[name]; !ok {
+			if err := syscall.Removexattr(path, name); err != nil && err != syscall.ENODATA {
+				return err
+			}
+		}
+	}
+	for name, value := range xattrs {
+		if !strings.HasPrefix(name, xattrNamespace) || bytes.Equal(current[name], value) {
+			continue
+		}
+		if err := syscall.Setxattr(path, name, value, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readXattr calls read with a buffer of the size it reports, retrying when
+// the value grew in between
+func readXattr(read func(buf []byte) (int, error)) ([]byte, error) {
+	for {
+		size, err := read(nil)
+		if err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			return []byte{}, nil
+		}
+		buf := make([]byte, size)
+		n, err := read(buf)
+		if err == syscall.ERANGE {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package surfstore
+
+// extended attributes are only synced on Linux, elsewhere files have none
+
+func getXattrs(path string) (map[string][]byte, error) {
+	return nil, nil
+}
+
+func setXattrs(path string, xattrs map[string][]byte) error {
+	return nil
+}